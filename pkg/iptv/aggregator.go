@@ -0,0 +1,416 @@
+package iptv
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// AggregateSource is one provider of an Aggregator
+type AggregateSource struct {
+	// Name identifies the source in StreamSource results
+	Name       string
+	Streams    StreamService
+	Categories CategoryService
+
+	// URL builds the playback URL of a stream of this source
+	URL func(stream Stream, format string) string
+}
+
+// ClientSource wraps an Xtream client as an aggregate source. URLs are built
+// on the host the client currently uses.
+func ClientSource(name string, c *Client) AggregateSource {
+	return AggregateSource{
+		Name:       name,
+		Streams:    c.StreamService(),
+		Categories: c.CategoryService(),
+		URL: func(stream Stream, format string) string {
+			return c.URLBuilder().StreamURL(stream, format)
+		},
+	}
+}
+
+// M3USource wraps an M3U playlist as an aggregate source
+func M3USource(name string, c *M3UClient) AggregateSource {
+	return AggregateSource{
+		Name:       name,
+		Streams:    c,
+		Categories: c,
+		URL: func(stream Stream, format string) string {
+			u, _ := c.GetURL(context.Background(), stream.ID, format)
+			return u
+		},
+	}
+}
+
+// StreamSource is one provider of an aggregated stream
+type StreamSource struct {
+	Source string
+	Stream Stream
+	URL    string
+}
+
+// AggregatorOption configures an Aggregator
+type AggregatorOption func(*Aggregator)
+
+// WithStreamIdentity sets the function deciding which streams of different
+// sources are the same channel or movie. Streams with an empty identity are
+// never merged.
+func WithStreamIdentity(identity func(Stream) string) AggregatorOption {
+	return func(a *Aggregator) {
+		a.identity = identity
+	}
+}
+
+// Aggregator merges several sources into one virtual catalog behind the
+// StreamService and CategoryService interfaces. Sources are given in
+// priority order.
+//
+// Categories with the same name are merged. Streams with the same identity
+// are de-duplicated: the stream of the first source is kept and Sources
+// lists every provider in priority order. Aggregator IDs are derived from
+// the source and its stream ID, so they are the same across fetches,
+// restarts and instances; a merged stream has the ID of its first source.
+type Aggregator struct {
+	sources  []AggregateSource
+	identity func(Stream) string
+
+	mu      sync.RWMutex
+	streams map[string]map[int][]StreamSource // by kind, then aggregator ID
+}
+
+var (
+	_ StreamService   = (*Aggregator)(nil)
+	_ CategoryService = (*Aggregator)(nil)
+)
+
+// NewAggregator creates an aggregator over sources in priority order
+func NewAggregator(sources []AggregateSource, opts ...AggregatorOption) *Aggregator {
+	a := &Aggregator{
+		sources:  sources,
+		identity: defaultStreamIdentity,
+		streams:  map[string]map[int][]StreamSource{},
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// defaultStreamIdentity identifies live channels by country, base name and
+// quality, and VOD by title and year
+func defaultStreamIdentity(s Stream) string {
+	if s.Type == "movie" {
+		title := s.MediaTitle()
+		return "movie:" + strings.Join(searchTokens(title.Title), " ") + ":" + formatYear(title.Year)
+	}
+
+	name := s.ChannelName()
+	base := strings.Join(searchTokens(name.Base), " ")
+	if base == "" {
+		return ""
+	}
+	return strings.ToLower(name.Country) + ":" + base + ":" + name.Quality
+}
+
+// StreamService returns the aggregator as a StreamService
+func (a *Aggregator) StreamService() StreamService {
+	return a
+}
+
+// CategoryService returns the aggregator as a CategoryService
+func (a *Aggregator) CategoryService() CategoryService {
+	return a
+}
+
+// aggregateIDBits is the number of low bits of an aggregator ID holding the
+// stream ID of the source. The source index is stored above them. 64-bit
+// platforms keep 32 bits for stream IDs, 32-bit platforms only 24.
+const aggregateIDBits = strconv.IntSize/4 + 16
+
+// aggregateID packs a source index and a stream ID of that source into an
+// aggregator ID. IDs that do not fit are an error rather than a collision.
+func aggregateID(source, id int) (int, error) {
+	if id < 0 || id >= 1<<aggregateIDBits || source >= 1<<(strconv.IntSize-1-aggregateIDBits) {
+		return 0, fmt.Errorf("stream ID %d does not fit an aggregator ID", id)
+	}
+	return source<<aggregateIDBits | id, nil
+}
+
+// origin decodes an aggregator ID into the source index and its stream ID
+func (a *Aggregator) origin(id int) (source, sourceID int, ok bool) {
+	if id < 0 {
+		return 0, 0, false
+	}
+	source, sourceID = id>>aggregateIDBits, id&(1<<aggregateIDBits-1)
+	return source, sourceID, source < len(a.sources)
+}
+
+// aggregateCategoryID returns the merged ID of a category name
+func aggregateCategoryID(name string) string {
+	return strconv.Itoa(aggregateCategoryNumber(name))
+}
+
+// aggregateCategoryNumber hashes a category name into 31 bits, so that the
+// ID also fits ParentID on 32-bit platforms
+func aggregateCategoryNumber(name string) int {
+	h := fnv.New32a()
+	io.WriteString(h, strings.Join(searchTokens(name), " "))
+	return int(h.Sum32() & 0x7fffffff)
+}
+
+func (a *Aggregator) GetLive(ctx context.Context, opts ...RequestOption) ([]Stream, error) {
+	return a.getStreams(ctx, "live", StreamService.GetLive, CategoryService.GetLiveCategories, a.GetLiveCategories, opts)
+}
+
+func (a *Aggregator) GetVOD(ctx context.Context, opts ...RequestOption) ([]Stream, error) {
+	return a.getStreams(ctx, "movie", StreamService.GetVOD, CategoryService.GetVODCategories, a.GetVODCategories, opts)
+}
+
+func (a *Aggregator) getStreams(
+	ctx context.Context,
+	kind string,
+	fetch func(StreamService, context.Context, ...RequestOption) ([]Stream, error),
+	fetchCategories func(CategoryService, context.Context, ...RequestOption) ([]Category, error),
+	merged func(context.Context, ...RequestOption) ([]Category, error),
+	opts []RequestOption,
+) ([]Stream, error) {
+	options := &RequestOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	// Cache bypass and stale tracking reach the sources through ctx
+	ctx, done := options.context(ctx)
+	defer done()
+
+	categoryIDs, err := resolveCategoryIDs(ctx, options, merged)
+	if err != nil {
+		return nil, err
+	}
+	selected := map[string]bool{}
+	for _, id := range categoryIDs {
+		selected[id] = true
+	}
+
+	// The categories map the IDs of every source to merged ones, so adult
+	// ones are needed too
+	categories := make([][]Category, len(a.sources))
+	fetchSourceCategories := func(ctx context.Context, i int) (err error) {
+		categories[i], err = fetchCategories(a.sources[i].Categories, ctx, unlockParental())
+		return err
+	}
+
+	// Sources are only asked for their own IDs of the selected categories
+	streams := make([][]Stream, len(a.sources))
+	fetchSourceStreams := func(ctx context.Context, i int) (err error) {
+		sourceOpts := []RequestOption{WithPIN(options.PIN)}
+		if !selected[""] {
+			var ids []string
+			for _, cat := range categories[i] {
+				if selected[aggregateCategoryID(cat.Name)] {
+					ids = append(ids, cat.ID)
+				}
+			}
+			if len(ids) == 0 {
+				return nil
+			}
+			sourceOpts = append(sourceOpts, WithCategoryIDs(ids...))
+		}
+		streams[i], err = fetch(a.sources[i].Streams, ctx, sourceOpts...)
+		return err
+	}
+
+	if selected[""] {
+		err = a.eachSource(ctx, func(ctx context.Context, i int) error {
+			return runConcurrently(ctx,
+				func(ctx context.Context) error { return fetchSourceCategories(ctx, i) },
+				func(ctx context.Context) error { return fetchSourceStreams(ctx, i) },
+			)
+		})
+	} else if err = a.eachSource(ctx, fetchSourceCategories); err == nil {
+		err = a.eachSource(ctx, fetchSourceStreams)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Merge duplicates in priority order
+	result := make([]Stream, 0)
+	sources := map[int][]StreamSource{}
+	byIdentity := map[string]int{}
+	for i, source := range a.sources {
+		categoryIDs := make(map[string]string, len(categories[i]))
+		for _, cat := range categories[i] {
+			categoryIDs[cat.ID] = aggregateCategoryID(cat.Name)
+		}
+
+		for _, stream := range streams[i] {
+			entry := StreamSource{Source: source.Name, Stream: stream}
+			if source.URL != nil {
+				entry.URL = source.URL(stream, "")
+			}
+
+			identity := a.identity(stream)
+			if at, ok := byIdentity[identity]; ok && identity != "" {
+				id := result[at].ID
+				sources[id] = append(sources[id], entry)
+				continue
+			}
+
+			if stream.ID, err = aggregateID(i, stream.ID); err != nil {
+				return nil, fmt.Errorf("source %s: %w", source.Name, err)
+			}
+			stream.CategoryID = categoryIDs[stream.CategoryID]
+			sources[stream.ID] = []StreamSource{entry}
+			byIdentity[identity] = len(result)
+			result = append(result, stream)
+		}
+	}
+
+	// A full listing replaces the known streams, so that the ones no longer
+	// listed are forgotten
+	a.mu.Lock()
+	if selected[""] || a.streams[kind] == nil {
+		a.streams[kind] = sources
+	} else {
+		for id, entries := range sources {
+			a.streams[kind][id] = entries
+		}
+	}
+	a.mu.Unlock()
+
+	return streamSchema.apply(result, options)
+}
+
+// eachSource runs fn for every source concurrently
+func (a *Aggregator) eachSource(ctx context.Context, fn func(ctx context.Context, i int) error) error {
+	tasks := make([]func(context.Context) error, len(a.sources))
+	for i, source := range a.sources {
+		tasks[i] = func(ctx context.Context) error {
+			if err := fn(ctx, i); err != nil {
+				return fmt.Errorf("source %s: %w", source.Name, err)
+			}
+			return nil
+		}
+	}
+	return runConcurrently(ctx, tasks...)
+}
+
+// listed returns the providers of a stream as of the last listing. Must be
+// called with a.mu held.
+func (a *Aggregator) listed(id int) []StreamSource {
+	for _, streams := range a.streams {
+		if sources, ok := streams[id]; ok {
+			return sources
+		}
+	}
+	return nil
+}
+
+// Sources returns the providers of an aggregated stream in priority order,
+// as of the last GetLive or GetVOD that listed it. For a stream that was not
+// listed yet, only the source its ID belongs to is returned, without URL.
+func (a *Aggregator) Sources(id int) []StreamSource {
+	a.mu.RLock()
+	listed := append([]StreamSource(nil), a.listed(id)...)
+	a.mu.RUnlock()
+	if len(listed) > 0 {
+		return listed
+	}
+
+	source, sourceID, ok := a.origin(id)
+	if !ok {
+		return nil
+	}
+	return []StreamSource{{Source: a.sources[source].Name, Stream: Stream{ID: sourceID}}}
+}
+
+// GetURL returns the URL of the highest priority source of a stream. The
+// source is decoded from the ID, so the stream does not need to be listed
+// first.
+func (a *Aggregator) GetURL(ctx context.Context, streamID int, format string) (string, error) {
+	index, sourceID, ok := a.origin(streamID)
+	if !ok {
+		return "", fmt.Errorf("%w: %d", ErrStreamNotFound, streamID)
+	}
+	source := a.sources[index]
+
+	a.mu.RLock()
+	listed := a.listed(streamID)
+	a.mu.RUnlock()
+	if len(listed) > 0 && source.URL != nil {
+		return source.URL(listed[0].Stream, format), nil
+	}
+	return source.Streams.GetURL(ctx, sourceID, format)
+}
+
+func (a *Aggregator) GetLiveCategories(ctx context.Context, opts ...RequestOption) ([]Category, error) {
+	return a.getCategories(ctx, CategoryService.GetLiveCategories, opts)
+}
+
+func (a *Aggregator) GetVODCategories(ctx context.Context, opts ...RequestOption) ([]Category, error) {
+	return a.getCategories(ctx, CategoryService.GetVODCategories, opts)
+}
+
+func (a *Aggregator) GetSeriesCategories(ctx context.Context, opts ...RequestOption) ([]Category, error) {
+	return a.getCategories(ctx, CategoryService.GetSeriesCategories, opts)
+}
+
+// getCategories merges the categories of every source by name
+func (a *Aggregator) getCategories(ctx context.Context, fetch func(CategoryService, context.Context, ...RequestOption) ([]Category, error), opts []RequestOption) ([]Category, error) {
+	options := &RequestOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	ctx, done := options.context(ctx)
+	defer done()
+
+	access := WithPIN(options.PIN)
+	if options.unlocked {
+		access = unlockParental()
+	}
+
+	lists := make([][]Category, len(a.sources))
+	err := a.eachSource(ctx, func(ctx context.Context, i int) (err error) {
+		lists[i], err = fetch(a.sources[i].Categories, ctx, access)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Parents are merged by name too, so the tree of the first source that
+	// lists a category is kept
+	categories := make([]Category, 0)
+	seen := map[string]bool{}
+	for _, list := range lists {
+		names := make(map[string]string, len(list))
+		for _, cat := range list {
+			names[cat.ID] = cat.Name
+		}
+
+		for _, cat := range list {
+			id := aggregateCategoryID(cat.Name)
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			merged := Category{ID: id, Name: cat.Name, Type: cat.Type}
+			if parent, ok := names[strconv.Itoa(cat.ParentID)]; ok && cat.ParentID != 0 {
+				if parentID := aggregateCategoryNumber(parent); strconv.Itoa(parentID) != id {
+					merged.ParentID = parentID
+				}
+			}
+			categories = append(categories, merged)
+		}
+	}
+
+	return categorySchema.apply(categories, options)
+}
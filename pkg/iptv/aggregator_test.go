@@ -0,0 +1,198 @@
+package iptv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// aggregatePanel is a panel whose get_live_streams answers filter by
+// category_id and record the requested categories
+type aggregatePanel struct {
+	*testPanel
+
+	mu        sync.Mutex
+	requested []string
+}
+
+func newAggregatePanel(t *testing.T, categories []Category, streams []Stream) *aggregatePanel {
+	t.Helper()
+
+	p := &aggregatePanel{testPanel: newTestPanel(t, map[string]any{
+		"get_live_categories": categories,
+	})}
+	p.handle("get_live_streams", func(w http.ResponseWriter, r *http.Request) {
+		categoryID := r.URL.Query().Get("category_id")
+
+		p.mu.Lock()
+		p.requested = append(p.requested, categoryID)
+		p.mu.Unlock()
+
+		listed := make([]Stream, 0)
+		for _, stream := range streams {
+			if categoryID == "" || stream.CategoryID == categoryID {
+				listed = append(listed, stream)
+			}
+		}
+		json.NewEncoder(w).Encode(listed)
+	})
+	p.handle("get_stream_info", func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.Atoi(r.URL.Query().Get("stream_id"))
+		for _, stream := range streams {
+			if stream.ID == id {
+				json.NewEncoder(w).Encode(stream)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	})
+	return p
+}
+
+func (p *aggregatePanel) requestedCategories() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return slices.Sorted(slices.Values(p.requested))
+}
+
+func newTestAggregator(t *testing.T) (*Aggregator, *aggregatePanel, *aggregatePanel) {
+	t.Helper()
+
+	main := newAggregatePanel(t,
+		[]Category{
+			{ID: "1", Name: "News"},
+			{ID: "2", Name: "Sports"},
+			{ID: "3", Name: "UK News", ParentID: 1},
+		},
+		[]Stream{
+			{ID: 100, Name: "BBC One", Type: "live", CategoryID: "3"},
+			{ID: 101, Name: "ESPN", Type: "live", CategoryID: "2"},
+		},
+	)
+	backup := newAggregatePanel(t,
+		[]Category{
+			{ID: "7", Name: "News"},
+			{ID: "8", Name: "UK News", ParentID: 7},
+		},
+		[]Stream{
+			{ID: 100, Name: "BBC One", Type: "live", CategoryID: "8"},
+			{ID: 200, Name: "CNN", Type: "live", CategoryID: "7"},
+		},
+	)
+
+	agg := NewAggregator([]AggregateSource{
+		ClientSource("main", newTestClient(t, main.URL)),
+		ClientSource("backup", newTestClient(t, backup.URL)),
+	})
+	return agg, main, backup
+}
+
+func mustAggregateID(t *testing.T, source, id int) int {
+	t.Helper()
+
+	aggID, err := aggregateID(source, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aggID
+}
+
+func TestAggregatorIDs(t *testing.T) {
+	agg, _, _ := newTestAggregator(t)
+	ctx := context.Background()
+
+	streams, err := agg.GetLive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := map[string]int{}
+	for _, stream := range streams {
+		ids[stream.Name] = stream.ID
+	}
+	want := map[string]int{
+		"BBC One": mustAggregateID(t, 0, 100),
+		"ESPN":    mustAggregateID(t, 0, 101),
+		"CNN":     mustAggregateID(t, 1, 200),
+	}
+	if len(ids) != len(want) {
+		t.Errorf("GetLive() = %+v", streams)
+	}
+	for name, id := range want {
+		if ids[name] != id {
+			t.Errorf("ID of %s = %d, want %d", name, ids[name], id)
+		}
+	}
+
+	sources := agg.Sources(want["BBC One"])
+	if len(sources) != 2 || sources[0].Source != "main" || sources[1].Source != "backup" {
+		t.Errorf("Sources(BBC One) = %+v", sources)
+	}
+
+	// Another aggregator resolves the IDs without listing the streams
+	fresh, _, backup := newTestAggregator(t)
+	u, err := fresh.GetURL(ctx, want["CNN"], "ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(u, backup.URL) || !strings.Contains(u, "/200.") {
+		t.Errorf("GetURL(CNN) = %q", u)
+	}
+	if n := backup.count("get_live_streams"); n != 0 {
+		t.Errorf("GetURL() listed the streams %d times", n)
+	}
+	if sources := fresh.Sources(want["CNN"]); len(sources) != 1 || sources[0].Source != "backup" || sources[0].Stream.ID != 200 {
+		t.Errorf("Sources(CNN) before listing = %+v", sources)
+	}
+
+	if _, err := agg.GetURL(ctx, mustAggregateID(t, 2, 1), "ts"); err == nil {
+		t.Error("GetURL() of an unknown source succeeded")
+	}
+}
+
+func TestAggregatorCategoryFilter(t *testing.T) {
+	agg, main, backup := newTestAggregator(t)
+
+	streams, err := agg.GetLive(context.Background(), WithCategoryName("^UK News$"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 1 || streams[0].Name != "BBC One" {
+		t.Errorf("GetLive() = %+v", streams)
+	}
+	if got := main.requestedCategories(); !slices.Equal(got, []string{"3"}) {
+		t.Errorf("main was asked for categories %q, want [3]", got)
+	}
+	if got := backup.requestedCategories(); !slices.Equal(got, []string{"8"}) {
+		t.Errorf("backup was asked for categories %q, want [8]", got)
+	}
+}
+
+func TestAggregatorCategoryParents(t *testing.T) {
+	agg, _, _ := newTestAggregator(t)
+
+	categories, err := agg.GetLiveCategories(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byName := map[string]Category{}
+	for _, cat := range categories {
+		byName[cat.Name] = cat
+	}
+	if len(byName) != 3 {
+		t.Errorf("GetLiveCategories() = %+v", categories)
+	}
+	if parent := strconv.Itoa(byName["UK News"].ParentID); parent != byName["News"].ID {
+		t.Errorf("parent of UK News = %s, want %s", parent, byName["News"].ID)
+	}
+	if byName["News"].ParentID != 0 {
+		t.Errorf("parent of News = %d, want 0", byName["News"].ParentID)
+	}
+}
@@ -0,0 +1,348 @@
+package iptv
+
+import (
+	"bufio"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache stores raw API responses. Implementations must be safe for
+// concurrent use.
+type Cache interface {
+	// Get returns a value that has not expired yet
+	Get(key string) ([]byte, bool)
+	// Set stores a value for ttl. Errors are logged by the client and do
+	// not fail the request.
+	Set(key string, value []byte, ttl time.Duration) error
+	// Invalidate removes every entry whose key starts with prefix. An empty
+	// prefix clears the cache.
+	Invalidate(prefix string)
+}
+
+// DefaultCacheTTLs are the cache lifetimes per Xtream action. Actions that
+// are not listed are never cached.
+var DefaultCacheTTLs = map[string]time.Duration{
+	"get_live_categories":   time.Hour,
+	"get_vod_categories":    time.Hour,
+	"get_series_categories": time.Hour,
+	"get_live_streams":      15 * time.Minute,
+	"get_vod_streams":       15 * time.Minute,
+	"get_series":            15 * time.Minute,
+	"get_series_info":       time.Hour,
+	"get_vod_info":          time.Hour,
+	"get_short_epg":         2 * time.Minute,
+	"get_simple_data_table": 15 * time.Minute,
+}
+
+// WithCache caches API responses with the TTLs of DefaultCacheTTLs
+func WithCache(cache Cache) Option {
+	return func(c *Client) error {
+		c.cache = cache
+		if c.cacheTTLs == nil {
+			c.cacheTTLs = copyTTLs(DefaultCacheTTLs)
+		}
+		return nil
+	}
+}
+
+// WithCacheTTL overrides the cache lifetime of an action. A zero TTL
+// disables caching for the action.
+func WithCacheTTL(action string, ttl time.Duration) Option {
+	return func(c *Client) error {
+		if c.cacheTTLs == nil {
+			c.cacheTTLs = copyTTLs(DefaultCacheTTLs)
+		}
+		c.cacheTTLs[action] = ttl
+		return nil
+	}
+}
+
+func copyTTLs(ttls map[string]time.Duration) map[string]time.Duration {
+	copied := make(map[string]time.Duration, len(ttls))
+	for action, ttl := range ttls {
+		copied[action] = ttl
+	}
+	return copied
+}
+
+// WithCacheBypass fetches fresh data from the provider and refreshes the
+// cache with it
+func WithCacheBypass() RequestOption {
+	return func(opts *RequestOptions) {
+		opts.bypassCache = true
+	}
+}
+
+// InvalidateCache removes the cached responses of some actions, or of every
+// action when none are given
+func (c *Client) InvalidateCache(actions ...string) {
+	if c.cache == nil {
+		return
+	}
+	if len(actions) == 0 {
+		c.cache.Invalidate("")
+		return
+	}
+	for _, action := range actions {
+		c.cache.Invalidate(action + "?")
+	}
+}
+
+type bypassCacheKey struct{}
+
+// cacheBypassed reports whether the request context asks to skip cached
+// responses
+func cacheBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassCacheKey{}).(bool)
+	return bypass
+}
+
+// cacheKey returns the cache key and TTL of an API request. Keys start with
+// the action so that they can be invalidated per action, and include the
+// server and user so that several accounts can share a cache.
+func (c *Client) cacheKey(params map[string]string) (string, time.Duration) {
+	if c.cache == nil {
+		return "", 0
+	}
+	action := params["action"]
+	ttl := c.cacheTTLs[action]
+	if ttl <= 0 {
+		return "", 0
+	}
+
+	values := url.Values{}
+	for k, v := range params {
+		values.Set(k, v)
+	}
+	// Mirrors serve the same data, so key on the primary host
+	values.Set("@server", c.hosts.hosts[0].baseURL)
+	values.Set("@user", c.config.Username)
+
+	return action + "?" + values.Encode(), ttl
+}
+
+// MemoryCache is an in-memory LRU cache
+type MemoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryCache creates an LRU cache holding at most maxEntries responses.
+// A maxEntries of 0 means no limit.
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		order:      list.New(),
+	}
+}
+
+func (m *MemoryCache) Get(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elem, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*memoryEntry)
+	if time.Now().After(entry.expires) {
+		m.remove(elem)
+		return nil, false
+	}
+
+	m.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+func (m *MemoryCache) Set(key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if elem, ok := m.entries[key]; ok {
+		m.remove(elem)
+	}
+	m.entries[key] = m.order.PushFront(&memoryEntry{key: key, value: value, expires: time.Now().Add(ttl)})
+
+	for m.maxEntries > 0 && m.order.Len() > m.maxEntries {
+		m.remove(m.order.Back())
+	}
+	return nil
+}
+
+func (m *MemoryCache) Invalidate(prefix string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, elem := range m.entries {
+		if strings.HasPrefix(key, prefix) {
+			m.remove(elem)
+		}
+	}
+}
+
+// Len returns the number of cached entries, including expired ones that
+// were not evicted yet
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.order.Len()
+}
+
+func (m *MemoryCache) remove(elem *list.Element) {
+	m.order.Remove(elem)
+	delete(m.entries, elem.Value.(*memoryEntry).key)
+}
+
+// DiskCache stores responses as files in a directory, so that they survive
+// restarts. Each file starts with its key and expiry time.
+type DiskCache struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewDiskCache creates a cache in dir, creating the directory if needed
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+func (d *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:])+".cache")
+}
+
+func (d *DiskCache) Get(key string) ([]byte, bool) {
+	f, err := os.Open(d.path(key))
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	storedKey, expires, err := readDiskHeader(r)
+	if err != nil || storedKey != key {
+		return nil, false
+	}
+	if time.Now().After(expires) {
+		d.removeExpired(key)
+		return nil, false
+	}
+
+	value, err := io.ReadAll(r)
+	if err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// removeExpired deletes the file of an expired entry. The expiry is checked
+// again under the lock, so that an entry a concurrent Set just wrote is kept.
+func (d *DiskCache) removeExpired(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	path := d.path(key)
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	_, expires, err := readDiskHeader(bufio.NewReader(f))
+	f.Close()
+	if err == nil && time.Now().After(expires) {
+		os.Remove(path)
+	}
+}
+
+func (d *DiskCache) Set(key string, value []byte, ttl time.Duration) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tmp, err := os.CreateTemp(d.dir, "tmp-*")
+	if err != nil {
+		return fmt.Errorf("error writing cache entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	w.WriteString(key + "\n")
+	w.WriteString(strconv.FormatInt(time.Now().Add(ttl).UnixNano(), 10) + "\n")
+	w.Write(value)
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing cache entry: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), d.path(key)); err != nil {
+		return fmt.Errorf("error writing cache entry: %w", err)
+	}
+	return nil
+}
+
+func (d *DiskCache) Invalidate(prefix string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	files, err := filepath.Glob(filepath.Join(d.dir, "*.cache"))
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		if prefix == "" {
+			os.Remove(file)
+			continue
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			continue
+		}
+		key, _, err := readDiskHeader(bufio.NewReader(f))
+		f.Close()
+		if err != nil || strings.HasPrefix(key, prefix) {
+			os.Remove(file)
+		}
+	}
+}
+
+// readDiskHeader reads the key and expiry lines of a cache file
+func readDiskHeader(r *bufio.Reader) (string, time.Time, error) {
+	key, err := r.ReadString('\n')
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	nanos, err := strconv.ParseInt(strings.TrimSuffix(line, "\n"), 10, 64)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return strings.TrimSuffix(key, "\n"), time.Unix(0, nanos), nil
+}
@@ -0,0 +1,162 @@
+package iptv
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientCache(t *testing.T) {
+	disk, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	caches := map[string]Cache{
+		"memory": NewMemoryCache(100),
+		"disk":   disk,
+	}
+
+	for name, cache := range caches {
+		t.Run(name, func(t *testing.T) {
+			panel := newTestPanel(t, map[string]any{
+				"get_live_streams": []Stream{{ID: 1, Name: "News One"}},
+			})
+			client := newTestClient(t, panel.URL, WithCache(cache))
+			ctx := context.Background()
+
+			get := func(opts ...RequestOption) {
+				t.Helper()
+				streams, err := client.StreamService().GetLive(ctx, opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(streams) != 1 || streams[0].Name != "News One" {
+					t.Fatalf("GetLive() = %+v", streams)
+				}
+			}
+
+			get()
+			get()
+			if n := panel.count("get_live_streams"); n != 1 {
+				t.Errorf("panel got %d requests for cached calls, want 1", n)
+			}
+
+			get(WithCacheBypass())
+			get()
+			if n := panel.count("get_live_streams"); n != 2 {
+				t.Errorf("panel got %d requests after a bypass, want 2", n)
+			}
+
+			client.InvalidateCache("get_live_streams")
+			get()
+			if n := panel.count("get_live_streams"); n != 3 {
+				t.Errorf("panel got %d requests after invalidating, want 3", n)
+			}
+		})
+	}
+}
+
+func TestClientCacheTTL(t *testing.T) {
+	panel := newTestPanel(t, map[string]any{
+		"get_live_streams":    []Stream{},
+		"get_live_categories": []Category{},
+	})
+	client := newTestClient(t, panel.URL,
+		WithCache(NewMemoryCache(100)),
+		WithCacheTTL("get_live_streams", 0),
+	)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.StreamService().GetLive(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.CategoryService().GetLiveCategories(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := panel.count("get_live_streams"); n != 2 {
+		t.Errorf("panel got %d requests for an uncached action, want 2", n)
+	}
+	if n := panel.count("get_live_categories"); n != 1 {
+		t.Errorf("panel got %d requests for a cached action, want 1", n)
+	}
+}
+
+// failingCache never stores anything
+type failingCache struct{}
+
+func (failingCache) Get(key string) ([]byte, bool) { return nil, false }
+
+func (failingCache) Set(key string, value []byte, ttl time.Duration) error {
+	return errors.New("disk full")
+}
+
+func (failingCache) Invalidate(prefix string) {}
+
+// testLogger records the messages of error logs
+type testLogger struct {
+	mu     sync.Mutex
+	errors []string
+}
+
+func (l *testLogger) Info(msg string, args ...interface{})  {}
+func (l *testLogger) Debug(msg string, args ...interface{}) {}
+
+func (l *testLogger) Error(msg string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.errors = append(l.errors, msg)
+}
+
+func TestClientCacheSetError(t *testing.T) {
+	panel := newTestPanel(t, map[string]any{
+		"get_live_streams": []Stream{{ID: 1, Name: "News One"}},
+	})
+	logger := &testLogger{}
+	client := newTestClient(t, panel.URL, WithCache(failingCache{}), WithLogger(logger))
+
+	streams, err := client.StreamService().GetLive(context.Background())
+	if err != nil || len(streams) != 1 {
+		t.Fatalf("GetLive() = %+v, %v", streams, err)
+	}
+	if len(logger.errors) != 1 {
+		t.Errorf("logged errors = %q, want one", logger.errors)
+	}
+}
+
+func TestDiskCacheExpiry(t *testing.T) {
+	cache, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cache.Set("get_live_streams?a", []byte("old"), -time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.Get("get_live_streams?a"); ok {
+		t.Error("Get() returned an expired entry")
+	}
+	if _, err := os.Stat(cache.path("get_live_streams?a")); !os.IsNotExist(err) {
+		t.Errorf("expired entry was not removed: %v", err)
+	}
+
+	// An entry rewritten after Get saw it expire is kept
+	if err := cache.Set("get_live_streams?b", []byte("new"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	cache.removeExpired("get_live_streams?b")
+	if value, ok := cache.Get("get_live_streams?b"); !ok || string(value) != "new" {
+		t.Errorf("Get() = %q, %v after removing expired entries", value, ok)
+	}
+
+	cache.Invalidate("get_live_streams?")
+	if _, ok := cache.Get("get_live_streams?b"); ok {
+		t.Error("Get() returned an invalidated entry")
+	}
+}
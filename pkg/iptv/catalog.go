@@ -0,0 +1,292 @@
+package iptv
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CatalogVersion is the version of the catalog format written by this package
+const CatalogVersion = 1
+
+// Catalog is a full snapshot of a provider: every category, live stream,
+// VOD and series, and optionally the episodes of every series
+type Catalog struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+
+	LiveCategories   []Category `json:"live_categories"`
+	VODCategories    []Category `json:"vod_categories"`
+	SeriesCategories []Category `json:"series_categories"`
+
+	Live   []Stream `json:"live"`
+	VOD    []Stream `json:"vod"`
+	Series []Series `json:"series"`
+
+	// Episodes maps series IDs to their episodes. It is only filled when the
+	// snapshot was taken WithEpisodes.
+	Episodes map[int][]Episode `json:"episodes,omitempty"`
+
+	// Guide maps EPG channel IDs to their programmes. It is only filled when
+	// the snapshot was taken WithGuide.
+	Guide map[string][]EPGInfo `json:"guide,omitempty"`
+}
+
+// SnapshotOption configures Client.Snapshot
+type SnapshotOption func(*snapshotOptions)
+
+type snapshotOptions struct {
+	episodes bool
+	guide    bool
+	workers  int
+}
+
+// WithEpisodes also fetches the episodes of every series, one request per
+// series
+func WithEpisodes() SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.episodes = true
+	}
+}
+
+// WithGuide also downloads the XMLTV guide so that the snapshot can answer
+// EPG queries offline
+func WithGuide() SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.guide = true
+	}
+}
+
+// WithSnapshotWorkers sets how many episode requests run concurrently. All
+// requests still share the client's rate limiter. Defaults to 4.
+func WithSnapshotWorkers(n int) SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.workers = n
+	}
+}
+
+// Snapshot fetches every category and every live, VOD and series item
+// concurrently within the client's rate limit. It fails rather than copy
+// data from the client's fallback snapshot.
+func (c *Client) Snapshot(ctx context.Context, opts ...SnapshotOption) (*Catalog, error) {
+	ctx = withoutFallback(ctx)
+
+	options := &snapshotOptions{workers: 4}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	catalog := &Catalog{
+		Version:   CatalogVersion,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	err := runConcurrently(ctx,
+		func(ctx context.Context) error {
+			if !options.guide {
+				return nil
+			}
+			data, err := c.epg.GetXMLTV(ctx)
+			if err != nil {
+				return err
+			}
+			catalog.Guide, err = ParseXMLTV(bytes.NewReader(data))
+			return err
+		},
+		func(ctx context.Context) (err error) {
+			catalog.LiveCategories, err = c.categories.GetLiveCategories(ctx)
+			return err
+		},
+		func(ctx context.Context) (err error) {
+			catalog.VODCategories, err = c.categories.GetVODCategories(ctx)
+			return err
+		},
+		func(ctx context.Context) (err error) {
+			catalog.SeriesCategories, err = c.categories.GetSeriesCategories(ctx)
+			return err
+		},
+		func(ctx context.Context) (err error) {
+			catalog.Live, err = c.streams.GetLive(ctx)
+			return err
+		},
+		func(ctx context.Context) (err error) {
+			catalog.VOD, err = c.streams.GetVOD(ctx)
+			return err
+		},
+		func(ctx context.Context) (err error) {
+			catalog.Series, err = c.series.GetSeries(ctx)
+			return err
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: %w", err)
+	}
+
+	if options.episodes {
+		catalog.Episodes, err = c.snapshotEpisodes(ctx, catalog.Series, options.workers)
+		if err != nil {
+			return nil, fmt.Errorf("snapshot: %w", err)
+		}
+	}
+
+	return catalog, nil
+}
+
+// snapshotEpisodes fetches the episodes of every series with a pool of workers
+func (c *Client) snapshotEpisodes(ctx context.Context, series []Series, workers int) (map[int][]Episode, error) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	ids := make(chan int)
+	episodes := make(map[int][]Episode, len(series))
+	var mu sync.Mutex
+
+	tasks := make([]func(context.Context) error, workers)
+	for i := range tasks {
+		tasks[i] = func(ctx context.Context) error {
+			for id := range ids {
+				list, err := c.series.GetEpisodes(ctx, id)
+				if err != nil {
+					return fmt.Errorf("series %d: %w", id, err)
+				}
+				mu.Lock()
+				episodes[id] = list
+				mu.Unlock()
+			}
+			return nil
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		defer close(ids)
+		for _, s := range series {
+			select {
+			case ids <- s.ID:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	if err := runConcurrently(ctx, tasks...); err != nil {
+		return nil, err
+	}
+	return episodes, ctx.Err()
+}
+
+// runConcurrently runs the tasks in parallel and returns the first error.
+// The context passed to the tasks is cancelled as soon as one fails.
+func runConcurrently(ctx context.Context, tasks ...func(context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for _, task := range tasks {
+		wg.Add(1)
+		go func(task func(context.Context) error) {
+			defer wg.Done()
+			if err := task(ctx); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(task)
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+// Encode writes the catalog as JSON, gzipped when compress is set
+func (c *Catalog) Encode(w io.Writer, compress bool) error {
+	if !compress {
+		return json.NewEncoder(w).Encode(c)
+	}
+
+	zw := gzip.NewWriter(w)
+	if err := json.NewEncoder(zw).Encode(c); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// DecodeCatalog reads a catalog written by Encode. Gzipped input is detected
+// automatically.
+func DecodeCatalog(r io.Reader) (*Catalog, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("error reading gzipped catalog: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	} else {
+		r = br
+	}
+
+	var catalog Catalog
+	if err := json.NewDecoder(r).Decode(&catalog); err != nil {
+		return nil, fmt.Errorf("error decoding catalog: %w", err)
+	}
+	if catalog.Version < 1 || catalog.Version > CatalogVersion {
+		return nil, fmt.Errorf("%w: version %d", ErrUnsupportedCatalog, catalog.Version)
+	}
+
+	return &catalog, nil
+}
+
+// SaveFile writes the catalog to a file, gzipped when the name ends in
+// ".gz". The file is replaced atomically.
+func (c *Catalog) SaveFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	if err := c.Encode(w, strings.HasSuffix(path, ".gz")); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadCatalogFile reads a catalog saved with SaveFile
+func LoadCatalogFile(path string) (*Catalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return DecodeCatalog(f)
+}
@@ -0,0 +1,229 @@
+package iptv
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Catch-up modes as used by the M3U catchup attribute
+const (
+	CatchupDefault = "default"
+	CatchupAppend  = "append"
+	CatchupShift   = "shift"
+	CatchupXC      = "xc"
+)
+
+// catchupMode returns the effective catch-up mode of a stream. Xtream streams
+// with tv_archive enabled use the "xc" mode.
+func (s Stream) catchupMode() string {
+	if s.Catchup != "" {
+		return s.Catchup
+	}
+	if s.TVArchive > 0 {
+		return CatchupXC
+	}
+	return ""
+}
+
+// catchupDays returns the size of the catch-up window in days
+func (s Stream) catchupDays() int {
+	if s.CatchupDays > 0 {
+		return s.CatchupDays
+	}
+	return int(s.TVArchiveDuration)
+}
+
+// TimeshiftURL returns the Xtream timeshift URL for a past programme
+func (b *URLBuilder) TimeshiftURL(streamID int, start time.Time, duration time.Duration, format string) string {
+	if format == "" {
+		format = "ts"
+	}
+
+	parts := []string{strings.TrimRight(b.BaseURL, "/"), "timeshift"}
+	if b.Username != "" || b.Password != "" {
+		parts = append(parts, b.Username, b.Password)
+	}
+	parts = append(parts,
+		strconv.Itoa(int(duration.Minutes())),
+		start.Format("2006-01-02:15-04"),
+		fmt.Sprintf("%d.%s", streamID, format))
+
+	return strings.Join(parts, "/")
+}
+
+// CatchupURL returns a playable URL for a past programme of a stream.
+// streamURL is the live URL of the stream. The programme times are shifted
+// by the stream's tvg-shift before they are used.
+func CatchupURL(stream Stream, streamURL string, programme EPGInfo) (string, error) {
+	return catchupURL(stream, streamURL, programme, time.Now())
+}
+
+func catchupURL(stream Stream, streamURL string, programme EPGInfo, now time.Time) (string, error) {
+	start, end := programmeWindow(programme)
+	if start.IsZero() {
+		return "", fmt.Errorf("%w: programme has no start time", ErrCatchupUnavailable)
+	}
+
+	if days := stream.catchupDays(); days > 0 && now.Sub(start) > time.Duration(days)*24*time.Hour {
+		return "", fmt.Errorf("%w: programme is older than %d days", ErrCatchupUnavailable, days)
+	}
+
+	shift := time.Duration(stream.TVGShift * float64(time.Hour))
+	programme.Start = start.Add(shift)
+	programme.End = end.Add(shift)
+
+	switch mode := stream.catchupMode(); mode {
+	case CatchupDefault:
+		if stream.CatchupSource == "" {
+			return "", fmt.Errorf("%w: missing catchup-source", ErrCatchupUnavailable)
+		}
+		return ExpandCatchupSource(stream.CatchupSource, programme, now), nil
+	case CatchupAppend:
+		if stream.CatchupSource == "" {
+			return "", fmt.Errorf("%w: missing catchup-source", ErrCatchupUnavailable)
+		}
+		return streamURL + ExpandCatchupSource(stream.CatchupSource, programme, now), nil
+	case CatchupShift:
+		sep := "?"
+		if strings.Contains(streamURL, "?") {
+			sep = "&"
+		}
+		return streamURL + ExpandCatchupSource(sep+"utc={utc}&lutc={lutc}", programme, now), nil
+	case CatchupXC:
+		return xcCatchupURL(stream, streamURL, programme)
+	case "":
+		return "", fmt.Errorf("%w: stream has no catch-up", ErrCatchupUnavailable)
+	default:
+		return "", fmt.Errorf("%w: unsupported catch-up mode %q", ErrCatchupUnavailable, mode)
+	}
+}
+
+// xcCatchupURL derives the timeshift URL from an Xtream live URL of the form
+// http://host/live/user/pass/id.ts or http://host/user/pass/id
+func xcCatchupURL(stream Stream, streamURL string, programme EPGInfo) (string, error) {
+	u, err := url.Parse(streamURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid stream URL: %w", err)
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) > 0 && segments[0] == "live" {
+		segments = segments[1:]
+	}
+	if len(segments) != 3 {
+		return "", fmt.Errorf("%w: %s is not an Xtream stream URL", ErrCatchupUnavailable, streamURL)
+	}
+
+	streamID := stream.ID
+	format := "ts"
+	last := segments[2]
+	if dot := strings.LastIndexByte(last, '.'); dot >= 0 {
+		format = last[dot+1:]
+		last = last[:dot]
+	}
+	if id, err := strconv.Atoi(last); err == nil {
+		streamID = id
+	}
+
+	builder := &URLBuilder{
+		BaseURL:  fmt.Sprintf("%s://%s", u.Scheme, u.Host),
+		Username: segments[0],
+		Password: segments[1],
+	}
+
+	return builder.TimeshiftURL(streamID, programme.Start, programme.End.Sub(programme.Start), format), nil
+}
+
+var catchupPlaceholder = regexp.MustCompile(`\$?\{([a-z-]+|[YmdHMS])(?::([^}]*))?\}`)
+
+// ExpandCatchupSource expands the placeholders of a catchup-source template
+// for a programme. Supported placeholders follow Kodi's PVR IPTV Simple:
+//
+//	{utc}, ${start}                 programme start as a unix timestamp
+//	{utcend}, ${end}                programme end as a unix timestamp
+//	{lutc}, ${now}, ${timestamp}    current time as a unix timestamp
+//	{duration}, {duration:N}        programme length in seconds, divided by N
+//	{offset:N}                      seconds since the programme start, divided by N
+//	{Y} {m} {d} {H} {M} {S}         programme start components
+//	{utc:FMT}, {utcend:FMT}, ...    timestamps formatted with Y, m, d, H, M, S
+func ExpandCatchupSource(source string, programme EPGInfo, now time.Time) string {
+	start, end := programmeWindow(programme)
+	start, end, now = start.UTC(), end.UTC(), now.UTC()
+
+	return catchupPlaceholder.ReplaceAllStringFunc(source, func(match string) string {
+		parts := catchupPlaceholder.FindStringSubmatch(match)
+		name, arg := parts[1], parts[2]
+
+		var t time.Time
+		switch name {
+		case "utc", "start":
+			t = start
+		case "utcend", "end":
+			t = end
+		case "lutc", "now", "timestamp":
+			t = now
+		case "duration":
+			return strconv.FormatInt(int64(end.Sub(start).Seconds())/catchupDivisor(arg), 10)
+		case "offset":
+			return strconv.FormatInt(int64(now.Sub(start).Seconds())/catchupDivisor(arg), 10)
+		case "Y", "m", "d", "H", "M", "S":
+			return formatCatchupTime(start, name)
+		default:
+			return match
+		}
+
+		if arg != "" {
+			return formatCatchupTime(t, arg)
+		}
+		return strconv.FormatInt(t.Unix(), 10)
+	})
+}
+
+func catchupDivisor(arg string) int64 {
+	if n, err := strconv.ParseInt(arg, 10, 64); err == nil && n > 0 {
+		return n
+	}
+	return 1
+}
+
+// formatCatchupTime replaces the Y, m, d, H, M and S letters of a format
+// with the corresponding zero-padded components of t
+func formatCatchupTime(t time.Time, format string) string {
+	var b strings.Builder
+	for _, r := range format {
+		switch r {
+		case 'Y':
+			fmt.Fprintf(&b, "%04d", t.Year())
+		case 'm':
+			fmt.Fprintf(&b, "%02d", int(t.Month()))
+		case 'd':
+			fmt.Fprintf(&b, "%02d", t.Day())
+		case 'H':
+			fmt.Fprintf(&b, "%02d", t.Hour())
+		case 'M':
+			fmt.Fprintf(&b, "%02d", t.Minute())
+		case 'S':
+			fmt.Fprintf(&b, "%02d", t.Second())
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// programmeWindow returns the start and end of a programme, falling back to
+// the unix timestamps when the parsed times are missing
+func programmeWindow(programme EPGInfo) (time.Time, time.Time) {
+	start, end := programme.Start, programme.End
+	if start.IsZero() && programme.StartStamp > 0 {
+		start = time.Unix(programme.StartStamp, 0)
+	}
+	if end.IsZero() && programme.StopStamp > 0 {
+		end = time.Unix(programme.StopStamp, 0)
+	}
+	return start, end
+}
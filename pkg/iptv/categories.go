@@ -0,0 +1,270 @@
+package iptv
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// CategoryNode is a category within a CategoryTree
+type CategoryNode struct {
+	Category
+	Parent   *CategoryNode
+	Children []*CategoryNode
+}
+
+// Path returns the nodes from the root down to this node
+func (n *CategoryNode) Path() []*CategoryNode {
+	var path []*CategoryNode
+	for node := n; node != nil; node = node.Parent {
+		path = append([]*CategoryNode{node}, path...)
+	}
+	return path
+}
+
+// Descendants returns every node below this one, depth first
+func (n *CategoryNode) Descendants() []*CategoryNode {
+	var nodes []*CategoryNode
+	for _, child := range n.Children {
+		nodes = append(nodes, child)
+		nodes = append(nodes, child.Descendants()...)
+	}
+	return nodes
+}
+
+// CategoryTree is the parent/child hierarchy of a list of categories.
+// Categories whose parent is 0 or unknown are roots.
+type CategoryTree struct {
+	Roots []*CategoryNode
+	nodes map[string]*CategoryNode
+}
+
+// NewCategoryTree builds the hierarchy of categories
+func NewCategoryTree(categories []Category) *CategoryTree {
+	tree := &CategoryTree{nodes: make(map[string]*CategoryNode, len(categories))}
+
+	ordered := make([]*CategoryNode, 0, len(categories))
+	for _, cat := range categories {
+		if _, ok := tree.nodes[cat.ID]; ok {
+			continue
+		}
+		node := &CategoryNode{Category: cat}
+		tree.nodes[cat.ID] = node
+		ordered = append(ordered, node)
+	}
+
+	for _, node := range ordered {
+		parent, ok := tree.nodes[strconv.Itoa(node.ParentID)]
+		if node.ParentID == 0 || !ok || parent.isWithin(node) {
+			tree.Roots = append(tree.Roots, node)
+			continue
+		}
+		node.Parent = parent
+		parent.Children = append(parent.Children, node)
+	}
+
+	return tree
+}
+
+// isWithin reports whether ancestor is n or one of its ancestors, which
+// guards against parent cycles
+func (n *CategoryNode) isWithin(ancestor *CategoryNode) bool {
+	for node := n; node != nil; node = node.Parent {
+		if node == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildCategoryTree fetches categories with a category service method and
+// builds their hierarchy:
+//
+//	tree, err := iptv.BuildCategoryTree(ctx, client.CategoryService().GetVODCategories)
+func BuildCategoryTree(ctx context.Context, fetch func(context.Context, ...RequestOption) ([]Category, error), opts ...RequestOption) (*CategoryTree, error) {
+	categories, err := fetch(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewCategoryTree(categories), nil
+}
+
+// Get returns the node of a category ID
+func (t *CategoryTree) Get(id string) (*CategoryNode, bool) {
+	node, ok := t.nodes[id]
+	return node, ok
+}
+
+// Len returns the number of categories in the tree
+func (t *CategoryTree) Len() int {
+	return len(t.nodes)
+}
+
+// Walk visits every node depth first. Returning false from fn skips the
+// children of the node.
+func (t *CategoryTree) Walk(fn func(node *CategoryNode, depth int) bool) {
+	var walk func(nodes []*CategoryNode, depth int)
+	walk = func(nodes []*CategoryNode, depth int) {
+		for _, node := range nodes {
+			if fn(node, depth) {
+				walk(node.Children, depth+1)
+			}
+		}
+	}
+	walk(t.Roots, 0)
+}
+
+// Resolve returns the IDs of the categories whose name matches the pattern,
+// followed by the IDs of their descendants
+func (t *CategoryTree) Resolve(pattern string) ([]string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid category name regex: %w", err)
+	}
+
+	var ids []string
+	seen := map[string]bool{}
+	add := func(node *CategoryNode) {
+		if !seen[node.ID] {
+			seen[node.ID] = true
+			ids = append(ids, node.ID)
+		}
+	}
+
+	t.Walk(func(node *CategoryNode, depth int) bool {
+		if !re.MatchString(node.Name) {
+			return true
+		}
+		add(node)
+		for _, child := range node.Descendants() {
+			add(child)
+		}
+		return false
+	})
+
+	return ids, nil
+}
+
+// WithCategoryName selects the categories whose name matches a regex. Names
+// are resolved to category IDs (including subcategories) before the streams
+// are fetched, one request per category.
+func WithCategoryName(pattern string) RequestOption {
+	return func(opts *RequestOptions) {
+		opts.CategoryName = pattern
+	}
+}
+
+// WithCategoryIDs fetches the streams of several categories concurrently
+// and merges them in the given category order
+func WithCategoryIDs(ids ...string) RequestOption {
+	return func(opts *RequestOptions) {
+		opts.CategoryIDs = append(opts.CategoryIDs, ids...)
+	}
+}
+
+// resolveCategoryIDs returns the category IDs selected by the options. An
+// empty ID stands for "all categories". list is only called when a category
+// name has to be resolved.
+func resolveCategoryIDs(ctx context.Context, options *RequestOptions, list func(context.Context, ...RequestOption) ([]Category, error)) ([]string, error) {
+	var ids []string
+	if options.CategoryID != "" {
+		ids = append(ids, options.CategoryID)
+	}
+	ids = append(ids, options.CategoryIDs...)
+
+	if options.CategoryName != "" {
+		tree, err := BuildCategoryTree(ctx, list, WithPIN(options.PIN))
+		if err != nil {
+			return nil, err
+		}
+		named, err := tree.Resolve(options.CategoryName)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, named...)
+
+		// A name that matches nothing selects nothing
+		if len(ids) == 0 {
+			return []string{}, nil
+		}
+	}
+
+	if len(ids) == 0 {
+		return []string{""}, nil
+	}
+
+	unique := ids[:0:0]
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique, nil
+}
+
+// categoryWorkers bounds how many category requests fetchByCategory runs
+// at the same time
+const categoryWorkers = 4
+
+// fetchByCategory calls fetch for every category ID with a pool of workers
+// and merges the results in category order, dropping items whose key was
+// already seen
+func fetchByCategory[T any, K comparable](ctx context.Context, ids []string, key func(*T) K, fetch func(ctx context.Context, categoryID string) ([]T, error)) ([]T, error) {
+	if len(ids) == 1 {
+		return fetch(ctx, ids[0])
+	}
+
+	results := make([][]T, len(ids))
+	indexes := make(chan int)
+
+	tasks := make([]func(context.Context) error, min(categoryWorkers, len(ids)))
+	for i := range tasks {
+		tasks[i] = func(ctx context.Context) error {
+			for i := range indexes {
+				items, err := fetch(ctx, ids[i])
+				if err != nil {
+					return fmt.Errorf("category %s: %w", ids[i], err)
+				}
+				results[i] = items
+			}
+			return nil
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		defer close(indexes)
+		for i := range ids {
+			select {
+			case indexes <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	if err := runConcurrently(ctx, tasks...); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	merged := make([]T, 0)
+	seen := map[K]bool{}
+	for _, items := range results {
+		for i := range items {
+			k := key(&items[i])
+			if !seen[k] {
+				seen[k] = true
+				merged = append(merged, items[i])
+			}
+		}
+	}
+	return merged, nil
+}
@@ -0,0 +1,78 @@
+package iptv
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFetchByCategory(t *testing.T) {
+	ids := make([]string, 20)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i)
+	}
+
+	var (
+		mu      sync.Mutex
+		running int
+		peak    int
+	)
+	fetch := func(ctx context.Context, categoryID string) ([]Stream, error) {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+
+		id, _ := strconv.Atoi(categoryID)
+		// Every category also holds the stream of the previous one
+		return []Stream{{ID: id}, {ID: id + 1}}, nil
+	}
+
+	streams, err := fetchByCategory(context.Background(), ids, streamKey, fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peak > categoryWorkers {
+		t.Errorf("%d concurrent fetches, want at most %d", peak, categoryWorkers)
+	}
+	if len(streams) != len(ids)+1 {
+		t.Fatalf("got %d streams, want %d", len(streams), len(ids)+1)
+	}
+	for i, stream := range streams {
+		if stream.ID != i {
+			t.Errorf("streams[%d].ID = %d, want %d", i, stream.ID, i)
+		}
+	}
+}
+
+func TestFetchByCategoryError(t *testing.T) {
+	errBoom := errors.New("boom")
+	fetch := func(ctx context.Context, categoryID string) ([]Stream, error) {
+		if categoryID == "3" {
+			return nil, errBoom
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+			return []Stream{{ID: 1}}, nil
+		}
+	}
+
+	_, err := fetchByCategory(context.Background(), []string{"1", "2", "3", "4", "5", "6"}, streamKey, fetch)
+	if !errors.Is(err, errBoom) {
+		t.Fatalf("error = %v, want %v", err, errBoom)
+	}
+	if err.Error() != "category 3: boom" {
+		t.Errorf("error = %q", err)
+	}
+}
@@ -0,0 +1,181 @@
+package iptv
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ChannelName is the structured form of a provider channel name such as
+// "UK | Sky Sports Main Event FHD ᴿᴬᵂ" or "|FR| TF1 HEVC"
+type ChannelName struct {
+	Raw string
+	// Base is the clean channel name without prefixes and tags
+	Base string
+	// Country is the upper-case country prefix, e.g. "UK" or "FR"
+	Country string
+	// Language is the lower-case language code implied by the prefix, e.g. "en"
+	Language string
+	// Quality is one of SD, HD, FHD, UHD, 4K or 8K
+	Quality string
+	// Codec is HEVC, H264 or similar when the name mentions it
+	Codec string
+	// Backup is set for backup or alternative feeds
+	Backup bool
+	// Tags holds other markers such as RAW, HDR or 60FPS
+	Tags []string
+}
+
+// QualityRank orders quality tags: 0 for unknown, then SD < HD < FHD < UHD = 4K < 8K
+func QualityRank(quality string) int {
+	switch strings.ToUpper(quality) {
+	case "SD":
+		return 1
+	case "HD":
+		return 2
+	case "FHD":
+		return 3
+	case "UHD", "4K":
+		return 4
+	case "8K":
+		return 5
+	default:
+		return 0
+	}
+}
+
+// prefixLanguages maps name prefixes to their country and language
+var prefixLanguages = map[string][2]string{
+	"UK": {"UK", "en"}, "GB": {"GB", "en"}, "US": {"US", "en"}, "USA": {"US", "en"},
+	"CA": {"CA", "en"}, "AU": {"AU", "en"}, "IE": {"IE", "en"}, "EN": {"", "en"},
+	"ENG": {"", "en"}, "FR": {"FR", "fr"}, "FRA": {"FR", "fr"}, "DE": {"DE", "de"},
+	"GER": {"DE", "de"}, "AT": {"AT", "de"}, "CH": {"CH", ""}, "ES": {"ES", "es"},
+	"ESP": {"ES", "es"}, "LAT": {"", "es"}, "MX": {"MX", "es"}, "IT": {"IT", "it"},
+	"ITA": {"IT", "it"}, "PT": {"PT", "pt"}, "POR": {"PT", "pt"}, "BR": {"BR", "pt"},
+	"NL": {"NL", "nl"}, "BE": {"BE", ""}, "PL": {"PL", "pl"}, "TR": {"TR", "tr"},
+	"GR": {"GR", "el"}, "RO": {"RO", "ro"}, "RU": {"RU", "ru"}, "SE": {"SE", "sv"},
+	"NO": {"NO", "no"}, "DK": {"DK", "da"}, "FI": {"FI", "fi"}, "AR": {"", "ar"},
+	"ARA": {"", "ar"}, "IN": {"IN", ""}, "PK": {"PK", ""},
+}
+
+// channelPrefix matches country/language prefixes like "UK | ", "|FR| ",
+// "[DE] ", "ES: " and "EN - "
+var channelPrefix = regexp.MustCompile(`^\s*[\[(|]?\s*([A-Za-z]{2,3})\s*(?:[\])|:•]+|\s+-)\s*`)
+
+// superscripts maps superscript and small capital letters used in channel
+// names to their plain form
+var superscripts = strings.NewReplacer(
+	"ᴬ", "A", "ᴮ", "B", "ᶜ", "C", "ᴰ", "D", "ᴱ", "E", "ᶠ", "F", "ᴳ", "G",
+	"ᴴ", "H", "ᴵ", "I", "ᴶ", "J", "ᴷ", "K", "ᴸ", "L", "ᴹ", "M", "ᴺ", "N",
+	"ᴼ", "O", "ᴾ", "P", "ᴿ", "R", "ˢ", "S", "ᵀ", "T", "ᵁ", "U", "ⱽ", "V",
+	"ᵂ", "W", "ʰ", "H", "ᵈ", "D", "⁰", "0", "¹", "1", "²", "2", "³", "3",
+	"⁴", "4", "⁵", "5", "⁶", "6", "⁷", "7", "⁸", "8", "⁹", "9",
+)
+
+var backupTag = regexp.MustCompile(`^(BACKUP|BACK-UP|BKP|BK|ALT|B/U)\d*$`)
+
+// ParseChannelName splits a provider channel name into its base name,
+// country prefix, quality, codec, backup marker and other tags
+func ParseChannelName(name string) ChannelName {
+	result := ChannelName{Raw: name}
+
+	rest := separateSuperscripts(name)
+
+	if m := channelPrefix.FindStringSubmatch(rest); m != nil {
+		code := strings.ToUpper(m[1])
+		// Unknown codes are only trusted when bracketed or pipe-delimited,
+		// "BT - Sport" is a name and not a country
+		delimited := strings.ContainsAny(m[0], "[(|")
+		if info, ok := prefixLanguages[code]; ok || len(code) == 2 && delimited {
+			result.Country, result.Language = code, ""
+			if ok {
+				result.Country, result.Language = info[0], info[1]
+			}
+			rest = rest[len(m[0]):]
+		}
+	}
+
+	rest = superscripts.Replace(rest)
+
+	var base []string
+	fields := strings.Fields(rest)
+	for i := 0; i < len(fields); i++ {
+		word := fields[i]
+		bracketed := strings.ContainsAny(word, "[(") || strings.ContainsAny(word, "])")
+		token := strings.ToUpper(strings.Trim(word, "[]()|-•*"))
+
+		// "FULL HD" is written as two words
+		if token == "FULL" && i+1 < len(fields) && strings.ToUpper(strings.Trim(fields[i+1], "[]()")) == "HD" {
+			result.Quality = "FHD"
+			i++
+			continue
+		}
+
+		switch {
+		case token == "":
+			// Separator between name parts
+			continue
+		case isQualityTag(token) != "":
+			result.Quality = isQualityTag(token)
+		case isCodecTag(token) != "":
+			result.Codec = isCodecTag(token)
+		case backupTag.MatchString(token) || (bracketed && token == "B"):
+			result.Backup = true
+		case token == "RAW" || token == "HDR" || token == "VIP" || strings.HasSuffix(token, "FPS") && len(token) > 3:
+			result.Tags = append(result.Tags, token)
+		default:
+			base = append(base, strings.Trim(word, "|•"))
+		}
+	}
+
+	result.Base = strings.Trim(strings.Join(base, " "), " -|:")
+	return result
+}
+
+// separateSuperscripts inserts spaces around runs of superscript letters so
+// that "FHDᴿᴬᵂ" becomes "FHD ᴿᴬᵂ"
+func separateSuperscripts(s string) string {
+	var b strings.Builder
+	prevSuper := false
+	for i, r := range s {
+		super := superscripts.Replace(string(r)) != string(r)
+		if i > 0 && super != prevSuper && r != ' ' {
+			b.WriteByte(' ')
+		}
+		b.WriteRune(r)
+		prevSuper = super
+	}
+	return b.String()
+}
+
+func isQualityTag(token string) string {
+	switch token {
+	case "SD", "HD", "FHD", "UHD", "4K", "8K":
+		return token
+	case "FULLHD":
+		return "FHD"
+	case "480P", "576P":
+		return "SD"
+	case "720P":
+		return "HD"
+	case "1080P", "1080I":
+		return "FHD"
+	case "2160P":
+		return "UHD"
+	}
+	return ""
+}
+
+func isCodecTag(token string) string {
+	switch token {
+	case "HEVC", "H265", "H.265", "X265":
+		return "HEVC"
+	case "H264", "H.264", "X264", "AVC":
+		return "H264"
+	}
+	return ""
+}
+
+// ChannelName parses the stream name into its structured form
+func (s Stream) ChannelName() ChannelName {
+	return ParseChannelName(s.Name)
+}
@@ -0,0 +1,104 @@
+package iptv
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseChannelName(t *testing.T) {
+	tests := []struct {
+		name string
+		want ChannelName
+	}{
+		{
+			name: "UK | Sky Sports Main Event FHD ᴿᴬᵂ",
+			want: ChannelName{Base: "Sky Sports Main Event", Country: "UK", Language: "en", Quality: "FHD", Tags: []string{"RAW"}},
+		},
+		{
+			name: "|FR| TF1 HEVC",
+			want: ChannelName{Base: "TF1", Country: "FR", Language: "fr", Codec: "HEVC"},
+		},
+		{
+			name: "[DE] Das Erste HD",
+			want: ChannelName{Base: "Das Erste", Country: "DE", Language: "de", Quality: "HD"},
+		},
+		{
+			name: "ES: La 1 1080p",
+			want: ChannelName{Base: "La 1", Country: "ES", Language: "es", Quality: "FHD"},
+		},
+		{
+			name: "EN - Discovery Channel",
+			want: ChannelName{Base: "Discovery Channel", Language: "en"},
+		},
+		{
+			name: "USA: CNN 4K",
+			want: ChannelName{Base: "CNN", Country: "US", Language: "en", Quality: "4K"},
+		},
+		{
+			name: "|XY| Unknown Channel",
+			want: ChannelName{Base: "Unknown Channel", Country: "XY"},
+		},
+		{
+			name: "BT - Sport 1",
+			want: ChannelName{Base: "BT Sport 1"},
+		},
+		{
+			name: "ZZ: Something",
+			want: ChannelName{Base: "ZZ: Something"},
+		},
+		{
+			name: "BBC One FULL HD",
+			want: ChannelName{Base: "BBC One", Quality: "FHD"},
+		},
+		{
+			name: "ITV 2 HD (Backup)",
+			want: ChannelName{Base: "ITV 2", Quality: "HD", Backup: true},
+		},
+		{
+			name: "Eurosport 1 [B]",
+			want: ChannelName{Base: "Eurosport 1", Backup: true},
+		},
+		{
+			name: "Plan B",
+			want: ChannelName{Base: "Plan B"},
+		},
+		{
+			name: "Canal+ UHD H.265 HDR 50FPS",
+			want: ChannelName{Base: "Canal+", Quality: "UHD", Codec: "HEVC", Tags: []string{"HDR", "50FPS"}},
+		},
+		{
+			name: "Sky Cinema ᴴᴰ",
+			want: ChannelName{Base: "Sky Cinema", Quality: "HD"},
+		},
+		{
+			name: "  News 24  ",
+			want: ChannelName{Base: "News 24"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseChannelName(tt.name)
+			if got.Raw != tt.name {
+				t.Errorf("Raw = %q, want %q", got.Raw, tt.name)
+			}
+			if got.Base != tt.want.Base || got.Country != tt.want.Country || got.Language != tt.want.Language ||
+				got.Quality != tt.want.Quality || got.Codec != tt.want.Codec || got.Backup != tt.want.Backup ||
+				!slices.Equal(got.Tags, tt.want.Tags) {
+				t.Errorf("ParseChannelName() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQualityRank(t *testing.T) {
+	order := []string{"", "sd", "HD", "FHD", "UHD", "8K"}
+	for i := 1; i < len(order); i++ {
+		if QualityRank(order[i-1]) >= QualityRank(order[i]) {
+			t.Errorf("QualityRank(%q) >= QualityRank(%q)", order[i-1], order[i])
+		}
+	}
+	if QualityRank("4K") != QualityRank("UHD") {
+		t.Error("4K and UHD should rank the same")
+	}
+}
@@ -0,0 +1,59 @@
+package iptv
+
+import "time"
+
+// CircuitState is the state of a host's circuit breaker
+type CircuitState int
+
+const (
+	// CircuitClosed lets requests through
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails requests fast until the cooldown has passed
+	CircuitOpen
+	// CircuitHalfOpen lets one trial request through to decide whether the
+	// host has recovered
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// WithCircuitBreaker stops sending requests to a host after threshold
+// consecutive connection errors or 5xx responses. After cooldown one trial
+// request is let through; its success closes the circuit and its failure
+// opens it for another cooldown. Requests fail with ErrCircuitOpen while
+// every host is open, or fall back to the snapshot when one is configured.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(c *Client) error {
+		c.hosts.threshold = threshold
+		if cooldown > 0 {
+			c.hosts.cooldown = cooldown
+		}
+		return nil
+	}
+}
+
+// WithCircuitStateHook calls fn whenever the circuit of a host changes
+// state. fn runs synchronously on the goroutine of the request that caused
+// the change.
+func WithCircuitStateHook(fn func(baseURL string, from, to CircuitState)) Option {
+	return func(c *Client) error {
+		c.hosts.onChange = fn
+		return nil
+	}
+}
+
+// CircuitStates returns the circuit state of every host of the client
+func (c *Client) CircuitStates() map[string]CircuitState {
+	return c.hosts.states()
+}
@@ -0,0 +1,147 @@
+package iptv
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyPanel is a panel whose get_live_streams fails until it is healed
+type flakyPanel struct {
+	*testPanel
+	healthy atomic.Bool
+}
+
+func newFlakyPanel(t *testing.T) *flakyPanel {
+	t.Helper()
+
+	p := &flakyPanel{testPanel: newTestPanel(t, nil)}
+	p.handle("get_live_streams", func(w http.ResponseWriter, r *http.Request) {
+		if !p.healthy.Load() {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`[{"stream_id":1,"name":"News One"}]`))
+	})
+	return p
+}
+
+// circuitLog records circuit state changes
+type circuitLog struct {
+	mu          sync.Mutex
+	transitions []string
+}
+
+func (l *circuitLog) hook(baseURL string, from, to CircuitState) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.transitions = append(l.transitions, from.String()+">"+to.String())
+}
+
+func (l *circuitLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return slices.Clone(l.transitions)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	const cooldown = 50 * time.Millisecond
+
+	panel := newFlakyPanel(t)
+	log := &circuitLog{}
+	client := newTestClient(t, panel.URL, WithCircuitBreaker(2, cooldown), WithCircuitStateHook(log.hook))
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.StreamService().GetLive(ctx); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("GetLive() %d = %v, want the panel's error", i, err)
+		}
+	}
+	if state := client.CircuitStates()[panel.URL]; state != CircuitOpen {
+		t.Fatalf("state after 2 failures = %s, want open", state)
+	}
+
+	// An open circuit fails fast
+	if _, err := client.StreamService().GetLive(ctx); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("GetLive() = %v, want ErrCircuitOpen", err)
+	}
+	if n := panel.count("get_live_streams"); n != 2 {
+		t.Errorf("panel got %d requests, want 2", n)
+	}
+
+	// A failed trial opens the circuit again
+	time.Sleep(cooldown)
+	if _, err := client.StreamService().GetLive(ctx); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("trial GetLive() = %v, want the panel's error", err)
+	}
+	if state := client.CircuitStates()[panel.URL]; state != CircuitOpen {
+		t.Errorf("state after a failed trial = %s, want open", state)
+	}
+
+	// A successful trial closes it
+	panel.healthy.Store(true)
+	time.Sleep(cooldown)
+	if _, err := client.StreamService().GetLive(ctx); err != nil {
+		t.Fatalf("trial GetLive() = %v", err)
+	}
+	if state := client.CircuitStates()[panel.URL]; state != CircuitClosed {
+		t.Errorf("state after a successful trial = %s, want closed", state)
+	}
+
+	want := []string{
+		"closed>open",
+		"open>half-open", "half-open>open",
+		"open>half-open", "half-open>closed",
+	}
+	if got := log.get(); !slices.Equal(got, want) {
+		t.Errorf("transitions = %q, want %q", got, want)
+	}
+}
+
+func TestCircuitBreakerMirror(t *testing.T) {
+	primary := newFlakyPanel(t)
+	mirror := newTestPanel(t, map[string]any{
+		"get_live_streams": []Stream{{ID: 1, Name: "News One"}},
+	})
+	client := newTestClientWithConfig(t,
+		&Config{BaseURL: primary.URL, BaseURLs: []string{mirror.URL}},
+		WithCircuitBreaker(1, time.Hour),
+	)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.StreamService().GetLive(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The open primary is skipped instead of being tried last
+	if n := primary.count("get_live_streams"); n != 1 {
+		t.Errorf("primary got %d requests, want 1", n)
+	}
+	if n := mirror.count("get_live_streams"); n != 3 {
+		t.Errorf("mirror got %d requests, want 3", n)
+	}
+}
+
+func TestCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	panel := newTestPanel(t, nil)
+	panel.handle("get_live_streams", failingHandler(http.StatusNotFound))
+	client := newTestClient(t, panel.URL, WithCircuitBreaker(1, time.Hour))
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.StreamService().GetLive(context.Background()); errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("GetLive() %d = %v", i, err)
+		}
+	}
+	if state := client.CircuitStates()[panel.URL]; state != CircuitClosed {
+		t.Errorf("state = %s, want closed", state)
+	}
+}
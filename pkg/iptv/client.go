@@ -5,8 +5,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"reflect"
 	"time"
 
 	"golang.org/x/time/rate"
@@ -20,11 +22,20 @@ type Client struct {
 	// Services
 	streams    StreamService
 	categories CategoryService
+	series     SeriesService
 	epg        EPGService
+	playlist   PlaylistService
 
 	// Middleware
-	rateLimiter *rate.Limiter
-	logger      Logger
+	rateLimiter  *adaptiveLimiter
+	heavyLimiter *adaptiveLimiter
+	logger       Logger
+	parental     *ParentalControl
+	fallback     func() *Catalog
+	cache        Cache
+	cacheTTLs    map[string]time.Duration
+	inflight     *coalescer
+	hosts        *hostPool
 }
 
 // Config holds the client configuration
@@ -32,11 +43,18 @@ type Config struct {
 	Username   string
 	Password   string
 	BaseURL    string
+	BaseURLs   []string // Mirrors of the same panel, tried in order after BaseURL
 	UserAgent  string
 	Timeout    time.Duration
 	MaxRetries int
 	RateLimit  rate.Limit
 	RateBurst  int
+
+	// HeavyRateLimit and HeavyRateBurst are the budget of requests that
+	// download whole catalogs, such as get_vod_streams without a category
+	// and xmltv.php. They default to RateLimit and RateBurst.
+	HeavyRateLimit rate.Limit
+	HeavyRateBurst int
 }
 
 // Logger interface for client logging
@@ -48,11 +66,69 @@ type Logger interface {
 
 // Get performs a GET request to the API
 func (c *Client) Get(ctx context.Context, params map[string]string, v interface{}) error {
-	if err := c.rateLimiter.Wait(ctx); err != nil {
-		return fmt.Errorf("rate limit exceeded: %w", err)
+	if c.inflight != nil && reflect.TypeOf(v).Kind() == reflect.Pointer {
+		return c.inflight.do(ctx, coalesceKey(ctx, params, v), v, func(ctx context.Context, v interface{}) error {
+			return c.get(ctx, params, v)
+		})
+	}
+	return c.get(ctx, params, v)
+}
+
+// get performs an API request through the cache, falling back to the
+// snapshot when the provider fails
+func (c *Client) get(ctx context.Context, params map[string]string, v interface{}) error {
+	key, ttl := c.cacheKey(params)
+	if ttl > 0 && !cacheBypassed(ctx) {
+		if data, ok := c.cache.Get(key); ok {
+			return decodeResponse(data, v)
+		}
 	}
 
-	baseURL := fmt.Sprintf("%s/player_api.php", c.config.BaseURL)
+	resp, err := c.do(ctx, "player_api.php", params)
+	if err != nil {
+		if c.answerFromSnapshot(ctx, params, v, err) {
+			return nil
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if ttl <= 0 {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return fmt.Errorf("error decoding response: %w", err)
+		}
+		return nil
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response: %w", err)
+	}
+	if err := decodeResponse(data, v); err != nil {
+		return err
+	}
+
+	// Only responses that decode are worth caching
+	if err := c.cache.Set(key, data, ttl); err != nil && c.logger != nil {
+		c.logger.Error("failed to cache response", "action", params["action"], "error", err)
+	}
+	return nil
+}
+
+func decodeResponse(data []byte, v interface{}) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
+
+// do performs a rate limited GET request against an endpoint of the panel.
+// Hosts that cannot be reached or fail with a server error are put on
+// cooldown and the request is retried on the next host. Hosts whose circuit
+// breaker is open are skipped, and ErrCircuitOpen is returned when no host
+// is left.
+// The caller is responsible for closing the response body.
+func (c *Client) do(ctx context.Context, endpoint string, params map[string]string) (*http.Response, error) {
 	values := url.Values{}
 	values.Set("username", c.config.Username)
 	values.Set("password", c.config.Password)
@@ -61,33 +137,91 @@ func (c *Client) Get(ctx context.Context, params map[string]string, v interface{
 		values.Set(k, v)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s?%s", baseURL, values.Encode()), nil)
+	limiter := c.rateLimiter
+	if isHeavy(endpoint, params) {
+		limiter = c.heavyLimiter
+	}
+
+	var lastErr error
+	for _, h := range c.hosts.order() {
+		if !c.hosts.allow(h) {
+			continue
+		}
+
+		resp, err := c.doHost(ctx, limiter, h.baseURL, endpoint, values)
+		switch {
+		case err == nil:
+			c.hosts.succeeded(h)
+			return resp, nil
+		case isHostFailure(ctx, err):
+			c.hosts.failed(h)
+			lastErr = err
+		default:
+			// The host answered, or the request was abandoned before it could
+			c.hosts.released(h)
+			return nil, err
+		}
+	}
+
+	if lastErr == nil {
+		return nil, c.hosts.openError()
+	}
+	return nil, lastErr
+}
+
+// doHost performs a rate limited GET request against one host. Responses
+// asking to slow down make the limiter back off.
+func (c *Client) doHost(ctx context.Context, limiter *adaptiveLimiter, baseURL, endpoint string, values url.Values) (*http.Response, error) {
+	if err := limiter.Wait(ctx); err != nil {
+		return nil, fmt.Errorf("rate limit exceeded: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/%s?%s", baseURL, endpoint, values.Encode()), nil)
 	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
+		return nil, fmt.Errorf("error creating request: %w", err)
 	}
 
 	req.Header.Set("User-Agent", c.config.UserAgent)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("error performing request: %w", err)
+		return nil, fmt.Errorf("error performing request: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	retryAfter, throttled := checkThrottle(resp)
+	if throttled {
+		limiter.throttled(retryAfter)
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
-		return fmt.Errorf("error decoding response: %w", err)
+	switch {
+	case resp.StatusCode != http.StatusOK:
+		resp.Body.Close()
+		return nil, &statusError{code: resp.StatusCode, throttled: throttled}
+	case throttled:
+		// Throttle message in a successful response
+		resp.Body.Close()
+		return nil, &statusError{code: http.StatusTooManyRequests, throttled: true}
 	}
 
-	return nil
+	return resp, nil
+}
+
+// AccountInfo returns the account and server information of the client's
+// credentials, including the active and maximum connection counts
+func (c *Client) AccountInfo(ctx context.Context) (*AccountInfo, error) {
+	var info AccountInfo
+	if err := c.Get(ctx, map[string]string{}, &info); err != nil {
+		return nil, err
+	}
+	if info.UserInfo.Auth == 0 && info.UserInfo.Username == "" {
+		return nil, ErrInvalidCredentials
+	}
+	return &info, nil
 }
 
-// BaseURL returns the base URL
+// BaseURL returns the base URL of the host requests currently go to
 func (c *Client) BaseURL() string {
-	return c.config.BaseURL
+	return c.hosts.order()[0].baseURL
 }
 
 // Username returns the username
@@ -103,28 +237,49 @@ func (c *Client) Password() string {
 // Option is a function that configures the client
 type Option func(*Client) error
 
+// WithLogger sets the logger of the client, e.g. for cache write failures
+func WithLogger(logger Logger) Option {
+	return func(c *Client) error {
+		c.logger = logger
+		return nil
+	}
+}
+
 // NewClient creates a new IPTV client
 func NewClient(cfg *Config, opts ...Option) (*Client, error) {
 	if cfg.Username == "" || cfg.Password == "" {
 		return nil, ErrInvalidCredentials
 	}
 
-	if cfg.BaseURL == "" {
+	hosts := newHostPool(append([]string{cfg.BaseURL}, cfg.BaseURLs...))
+	if len(hosts.hosts) == 0 {
 		return nil, ErrInvalidBaseURL
 	}
 
+	heavyLimit, heavyBurst := cfg.HeavyRateLimit, cfg.HeavyRateBurst
+	if heavyLimit == 0 {
+		heavyLimit = cfg.RateLimit
+	}
+	if heavyBurst == 0 {
+		heavyBurst = cfg.RateBurst
+	}
+
 	client := &Client{
 		config: cfg,
 		httpClient: &http.Client{
 			Timeout: cfg.Timeout,
 		},
-		rateLimiter: rate.NewLimiter(cfg.RateLimit, cfg.RateBurst),
+		rateLimiter:  newAdaptiveLimiter(cfg.RateLimit, cfg.RateBurst),
+		heavyLimiter: newAdaptiveLimiter(heavyLimit, heavyBurst),
+		hosts:        hosts,
 	}
 
 	// Initialize services
 	client.streams = newStreamService(client)
 	client.categories = newCategoryService(client)
+	client.series = newSeriesService(client)
 	client.epg = newEPGService(client)
+	client.playlist = newPlaylistService(client)
 
 	// Apply options
 	for _, opt := range opts {
@@ -146,7 +301,17 @@ func (c *Client) CategoryService() CategoryService {
 	return c.categories
 }
 
+// SeriesService returns the series service
+func (c *Client) SeriesService() SeriesService {
+	return c.series
+}
+
 // EPGService returns the EPG service
 func (c *Client) EPGService() EPGService {
 	return c.epg
 }
+
+// PlaylistService returns the playlist service
+func (c *Client) PlaylistService() PlaylistService {
+	return c.playlist
+}
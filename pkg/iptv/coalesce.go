@@ -0,0 +1,182 @@
+package iptv
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// WithRequestCoalescing shares one upstream request between concurrent
+// identical API calls. Callers receive their own deep copy of the decoded
+// result, which they may modify freely. A caller cancelling its context only stops waiting; the request
+// is cancelled once every caller has gone.
+func WithRequestCoalescing() Option {
+	return func(c *Client) error {
+		c.inflight = &coalescer{calls: map[string]*inflightCall{}}
+		return nil
+	}
+}
+
+// coalescer de-duplicates in-flight requests by key
+type coalescer struct {
+	mu    sync.Mutex
+	calls map[string]*inflightCall
+}
+
+type inflightCall struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+
+	// Set before done is closed
+	value reflect.Value
+	stale bool
+	err   error
+}
+
+// coalesceKey identifies identical requests: same parameters decoded into
+// the same type, with the same context options. A request bypassing the
+// cache or the fallback snapshot must not be answered by one that may use
+// them.
+func coalesceKey(ctx context.Context, params map[string]string, v interface{}) string {
+	values := url.Values{}
+	for k, val := range params {
+		values.Set(k, val)
+	}
+	if cacheBypassed(ctx) {
+		values.Set("@bypass", "1")
+	}
+	if fallbackDisabled(ctx) {
+		values.Set("@nofallback", "1")
+	}
+	return reflect.TypeOf(v).String() + "|" + values.Encode()
+}
+
+// do runs fetch once for all concurrent callers with the same key and
+// copies the decoded result into v. fetch decodes into the pointer it is
+// given and runs with a context that is only cancelled when every caller
+// has given up.
+func (g *coalescer) do(ctx context.Context, key string, v interface{}, fetch func(ctx context.Context, v interface{}) error) error {
+	g.mu.Lock()
+	call, ok := g.calls[key]
+	if !ok {
+		call = g.start(ctx, key, reflect.TypeOf(v).Elem(), fetch)
+	}
+	call.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		g.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			call.cancel()
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		return ctx.Err()
+	}
+
+	if call.stale {
+		markStale(ctx)
+	}
+	if call.err != nil {
+		return call.err
+	}
+
+	reflect.ValueOf(v).Elem().Set(copyValue(call.value))
+	return nil
+}
+
+// start launches the shared request. Must be called with g.mu held.
+func (g *coalescer) start(ctx context.Context, key string, typ reflect.Type, fetch func(ctx context.Context, v interface{}) error) *inflightCall {
+	// Keep the values of the first caller's context, e.g. cache bypass, but
+	// not its cancellation
+	shared, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stale := new(atomic.Bool)
+	shared = context.WithValue(shared, staleKey{}, stale)
+
+	call := &inflightCall{done: make(chan struct{}), cancel: cancel}
+	g.calls[key] = call
+
+	go func() {
+		defer cancel()
+
+		result := reflect.New(typ)
+		err := fetch(shared, result.Interface())
+
+		g.mu.Lock()
+		if g.calls[key] == call {
+			delete(g.calls, key)
+		}
+		g.mu.Unlock()
+
+		call.value, call.stale, call.err = result.Elem(), stale.Load(), err
+		close(call.done)
+	}()
+
+	return call
+}
+
+// copyValue returns a deep copy of a decoded result, so that callers can
+// modify their result without affecting the others. Unexported struct fields
+// are copied as they are.
+func copyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.New(v.Type().Elem())
+		copied.Elem().Set(copyValue(v.Elem()))
+		return copied
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(copyValue(v.Elem()))
+		return copied
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(copyValue(v.Index(i)))
+		}
+		return copied
+	case reflect.Array:
+		copied := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(copyValue(v.Index(i)))
+		}
+		return copied
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(iter.Key(), copyValue(iter.Value()))
+		}
+		return copied
+	case reflect.Struct:
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				copied.Field(i).Set(copyValue(v.Field(i)))
+			}
+		}
+		return copied
+	default:
+		return v
+	}
+}
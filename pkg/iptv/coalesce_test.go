@@ -0,0 +1,146 @@
+package iptv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockedPanel answers get_short_epg once release is closed
+func blockedPanel(t *testing.T) (*testPanel, chan struct{}) {
+	t.Helper()
+
+	release := make(chan struct{})
+	panel := newTestPanel(t, nil)
+	panel.handle("get_short_epg", func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		json.NewEncoder(w).Encode(EPGContainer{EPGListings: []EPGInfo{{Title: "Evening News"}}})
+	})
+	return panel, release
+}
+
+// waitForWaiters blocks until n callers wait for the in-flight requests
+func waitForWaiters(t *testing.T, client *Client, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		client.inflight.mu.Lock()
+		waiters := 0
+		for _, call := range client.inflight.calls {
+			waiters += call.waiters
+		}
+		client.inflight.mu.Unlock()
+
+		if waiters == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d callers are waiting, want %d", waiters, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRequestCoalescing(t *testing.T) {
+	panel, release := blockedPanel(t)
+	client := newTestClient(t, panel.URL, WithRequestCoalescing())
+
+	const callers = 5
+	results := make([][]EPGInfo, callers)
+	errs := make([]error, callers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = client.EPGService().GetShortEPG(context.Background(), "1", 0)
+		}(i)
+	}
+	waitForWaiters(t, client, callers)
+	close(release)
+	wg.Wait()
+
+	if n := panel.count("get_short_epg"); n != 1 {
+		t.Errorf("panel got %d requests, want 1", n)
+	}
+	for i := range results {
+		if errs[i] != nil || len(results[i]) != 1 || results[i][0].Title != "Evening News" {
+			t.Fatalf("caller %d got %+v, %v", i, results[i], errs[i])
+		}
+	}
+
+	// The nested listings are copied too
+	results[0][0].Title = "Changed"
+	for i := 1; i < callers; i++ {
+		if results[i][0].Title != "Evening News" {
+			t.Errorf("caller %d sees the change of caller 0: %+v", i, results[i])
+		}
+	}
+}
+
+func TestRequestCoalescingCancel(t *testing.T) {
+	panel, release := blockedPanel(t)
+	client := newTestClient(t, panel.URL, WithRequestCoalescing())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error, 1)
+	go func() {
+		_, err := client.EPGService().GetShortEPG(ctx, "1", 0)
+		cancelled <- err
+	}()
+
+	var result []EPGInfo
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		result, err = client.EPGService().GetShortEPG(context.Background(), "1", 0)
+	}()
+
+	waitForWaiters(t, client, 2)
+	cancel()
+	if err := <-cancelled; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled caller got %v, want context.Canceled", err)
+	}
+
+	close(release)
+	<-done
+	if err != nil || len(result) != 1 {
+		t.Errorf("remaining caller got %+v, %v", result, err)
+	}
+	if n := panel.count("get_short_epg"); n != 1 {
+		t.Errorf("panel got %d requests, want 1", n)
+	}
+}
+
+func TestCoalesceKey(t *testing.T) {
+	ctx := context.Background()
+	params := map[string]string{"action": "get_live_streams"}
+	var streams []Stream
+	var categories []Category
+
+	base := coalesceKey(ctx, params, &streams)
+	bypass, done := requestContext(ctx, []RequestOption{WithCacheBypass()})
+	defer done()
+
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{"other type", coalesceKey(ctx, params, &categories)},
+		{"other params", coalesceKey(ctx, map[string]string{"action": "get_live_streams", "category_id": "1"}, &streams)},
+		{"cache bypass", coalesceKey(bypass, params, &streams)},
+		{"no fallback", coalesceKey(withoutFallback(ctx), params, &streams)},
+	}
+	for _, tt := range tests {
+		if tt.key == base {
+			t.Errorf("%s shares the key %q", tt.name, base)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package iptv
+
+import (
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChangeKind is the kind of a catalog change
+type ChangeKind string
+
+const (
+	// ChangeAdded marks items only present in the new catalog
+	ChangeAdded ChangeKind = "added"
+	// ChangeRemoved marks items only present in the old catalog
+	ChangeRemoved ChangeKind = "removed"
+	// ChangeRenamed marks items whose ID stayed the same but whose name changed
+	ChangeRenamed ChangeKind = "renamed"
+	// ChangeMoved marks items that moved to another category, or categories
+	// that moved to another parent
+	ChangeMoved ChangeKind = "moved"
+	// ChangeModified marks items with other changed fields
+	ChangeModified ChangeKind = "changed"
+	// ChangeReordered marks items whose position in the provider's list
+	// (the num field) changed, which happens whenever items are inserted
+	// or removed before them
+	ChangeReordered ChangeKind = "reordered"
+)
+
+// ordinalFields are the JSON fields holding list positions. Their changes
+// are reported as ChangeReordered rather than ChangeModified.
+var ordinalFields = []string{"num"}
+
+// ChangeEntity is the type of catalog item a change refers to
+type ChangeEntity string
+
+// Catalog entities
+const (
+	EntityLive           ChangeEntity = "live"
+	EntityVOD            ChangeEntity = "vod"
+	EntitySeries         ChangeEntity = "series"
+	EntityEpisode        ChangeEntity = "episode"
+	EntityLiveCategory   ChangeEntity = "live_category"
+	EntityVODCategory    ChangeEntity = "vod_category"
+	EntitySeriesCategory ChangeEntity = "series_category"
+)
+
+// FieldChange is the old and new value of a changed field
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Change is a single difference between two catalogs
+type Change struct {
+	Kind   ChangeKind   `json:"kind"`
+	Entity ChangeEntity `json:"entity"`
+	ID     string       `json:"id"`
+	Name   string       `json:"name"`
+
+	// OldName is set for renames
+	OldName string `json:"old_name,omitempty"`
+
+	// OldCategoryID and CategoryID are set for moves. For categories they
+	// hold the parent IDs, for episodes the series ID.
+	OldCategoryID string `json:"old_category_id,omitempty"`
+	CategoryID    string `json:"category_id,omitempty"`
+
+	// Fields lists the other changed fields of ChangeModified changes and
+	// the changed position of ChangeReordered changes
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+// ChangeSet is the list of differences between two catalogs
+type ChangeSet struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Changes []Change  `json:"changes"`
+}
+
+// Empty reports whether the catalogs were identical
+func (cs *ChangeSet) Empty() bool {
+	return len(cs.Changes) == 0
+}
+
+// Filter returns the changes of a kind, optionally restricted to some
+// entities:
+//
+//	newMovies := changes.Filter(iptv.ChangeAdded, iptv.EntityVOD)
+func (cs *ChangeSet) Filter(kind ChangeKind, entities ...ChangeEntity) []Change {
+	var changes []Change
+	for _, change := range cs.Changes {
+		if change.Kind != kind {
+			continue
+		}
+		if len(entities) > 0 && !slices.Contains(entities, change.Entity) {
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// DiffCatalogs compares two catalogs. Items are matched by ID; a changed
+// name is reported as a rename, a changed category as a move and a changed
+// position as a reorder, other changed fields are reported together as one
+// ChangeModified change.
+// Episodes are only compared when both catalogs include them.
+func DiffCatalogs(old, new *Catalog) *ChangeSet {
+	cs := &ChangeSet{From: old.CreatedAt, To: new.CreatedAt, Changes: make([]Change, 0)}
+
+	categoryID := func(c *Category) string { return c.ID }
+	categoryName := func(c *Category) string { return c.Name }
+	categoryParent := func(c *Category) string { return strconv.Itoa(c.ParentID) }
+	categoryFields := []string{"category_name", "parent_id"}
+	cs.Changes = append(cs.Changes, diffItems(EntityLiveCategory, old.LiveCategories, new.LiveCategories, categoryID, categoryName, categoryParent, categoryFields)...)
+	cs.Changes = append(cs.Changes, diffItems(EntityVODCategory, old.VODCategories, new.VODCategories, categoryID, categoryName, categoryParent, categoryFields)...)
+	cs.Changes = append(cs.Changes, diffItems(EntitySeriesCategory, old.SeriesCategories, new.SeriesCategories, categoryID, categoryName, categoryParent, categoryFields)...)
+
+	streamID := func(s *Stream) string { return strconv.Itoa(s.ID) }
+	streamName := func(s *Stream) string { return s.Name }
+	streamCategory := func(s *Stream) string { return s.CategoryID }
+	streamFields := []string{"name", "category_id"}
+	cs.Changes = append(cs.Changes, diffItems(EntityLive, old.Live, new.Live, streamID, streamName, streamCategory, streamFields)...)
+	cs.Changes = append(cs.Changes, diffItems(EntityVOD, old.VOD, new.VOD, streamID, streamName, streamCategory, streamFields)...)
+
+	cs.Changes = append(cs.Changes, diffItems(EntitySeries, old.Series, new.Series,
+		func(s *Series) string { return strconv.Itoa(s.ID) },
+		func(s *Series) string { return s.Name },
+		func(s *Series) string { return s.CategoryID },
+		[]string{"name", "category_id"})...)
+
+	if old.Episodes != nil && new.Episodes != nil {
+		cs.Changes = append(cs.Changes, diffItems(EntityEpisode, flattenEpisodes(old), flattenEpisodes(new),
+			func(e *Episode) string { return strconv.Itoa(int(e.ID)) },
+			func(e *Episode) string { return e.Title },
+			func(e *Episode) string { return strconv.Itoa(e.SeriesID) },
+			[]string{"title", "series_id"})...)
+	}
+
+	return cs
+}
+
+// flattenEpisodes lists the episodes of a catalog in series order
+func flattenEpisodes(c *Catalog) []Episode {
+	var episodes []Episode
+	seen := map[int]bool{}
+	for _, s := range c.Series {
+		if !seen[s.ID] {
+			seen[s.ID] = true
+			episodes = append(episodes, c.Episodes[s.ID]...)
+		}
+	}
+	// Episodes of series missing from the series list
+	var orphans []int
+	for id := range c.Episodes {
+		if !seen[id] {
+			orphans = append(orphans, id)
+		}
+	}
+	sort.Ints(orphans)
+	for _, id := range orphans {
+		episodes = append(episodes, c.Episodes[id]...)
+	}
+	return episodes
+}
+
+// diffItems compares two lists of items matched by ID. Added and changed
+// items are reported in the order of the new list, removed items in the
+// order of the old list. The name and category fields are listed in skip so
+// that they are not reported again as modified fields.
+func diffItems[T any](entity ChangeEntity, old, new []T, id, name, category func(*T) string, skip []string) []Change {
+	var changes []Change
+
+	previous := make(map[string]*T, len(old))
+	for i := range old {
+		previous[id(&old[i])] = &old[i]
+	}
+
+	current := make(map[string]bool, len(new))
+	for i := range new {
+		item := &new[i]
+		key := id(item)
+		current[key] = true
+
+		before, ok := previous[key]
+		if !ok {
+			changes = append(changes, Change{Kind: ChangeAdded, Entity: entity, ID: key, Name: name(item), CategoryID: category(item)})
+			continue
+		}
+
+		if oldName := name(before); oldName != name(item) {
+			changes = append(changes, Change{Kind: ChangeRenamed, Entity: entity, ID: key, Name: name(item), OldName: oldName})
+		}
+		if oldCategory := category(before); oldCategory != category(item) {
+			changes = append(changes, Change{Kind: ChangeMoved, Entity: entity, ID: key, Name: name(item), OldCategoryID: oldCategory, CategoryID: category(item)})
+		}
+		fields := fieldChanges(before, item, skip)
+		ordinal := slices.DeleteFunc(slices.Clone(fields), func(f FieldChange) bool { return !slices.Contains(ordinalFields, f.Field) })
+		fields = slices.DeleteFunc(fields, func(f FieldChange) bool { return slices.Contains(ordinalFields, f.Field) })
+		if len(ordinal) > 0 {
+			changes = append(changes, Change{Kind: ChangeReordered, Entity: entity, ID: key, Name: name(item), Fields: ordinal})
+		}
+		if len(fields) > 0 {
+			changes = append(changes, Change{Kind: ChangeModified, Entity: entity, ID: key, Name: name(item), Fields: fields})
+		}
+	}
+
+	for i := range old {
+		item := &old[i]
+		if key := id(item); !current[key] {
+			changes = append(changes, Change{Kind: ChangeRemoved, Entity: entity, ID: key, Name: name(item), CategoryID: category(item)})
+		}
+	}
+
+	return changes
+}
+
+// fieldChanges compares the JSON fields of two items, leaving out the
+// skipped ones
+func fieldChanges[T any](old, new *T, skip []string) []FieldChange {
+	var fields []FieldChange
+
+	typ := reflect.TypeOf(old).Elem()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		key := strings.Split(field.Tag.Get("json"), ",")[0]
+		if key == "" || key == "-" || slices.Contains(skip, key) {
+			continue
+		}
+
+		get := fieldAccessor[T](field.Index)
+		before, after := get(old), get(new)
+		if before == after {
+			continue
+		}
+		fields = append(fields, FieldChange{Field: key, Old: before, New: after})
+	}
+
+	return fields
+}
@@ -0,0 +1,81 @@
+package iptv
+
+import "testing"
+
+func TestDiffCatalogs(t *testing.T) {
+	old := &Catalog{
+		LiveCategories: []Category{{ID: "1", Name: "News"}, {ID: "2", Name: "Sports"}},
+		Live: []Stream{
+			{ID: 10, Num: 1, Name: "BBC News", CategoryID: "1"},
+			{ID: 11, Num: 2, Name: "CNN", CategoryID: "1"},
+			{ID: 12, Num: 3, Name: "ESPN", CategoryID: "2", StreamIcon: "old.png"},
+			{ID: 13, Num: 4, Name: "Old Channel", CategoryID: "2"},
+		},
+	}
+	new := &Catalog{
+		LiveCategories: []Category{{ID: "1", Name: "World News"}, {ID: "2", Name: "Sports"}},
+		Live: []Stream{
+			{ID: 14, Num: 1, Name: "Al Jazeera", CategoryID: "1"},
+			{ID: 10, Num: 2, Name: "BBC News", CategoryID: "1"},
+			{ID: 11, Num: 3, Name: "CNN International", CategoryID: "2"},
+			{ID: 12, Num: 4, Name: "ESPN", CategoryID: "2", StreamIcon: "new.png"},
+		},
+	}
+
+	changes := DiffCatalogs(old, new)
+
+	type key struct {
+		kind ChangeKind
+		id   string
+	}
+	got := map[key]Change{}
+	for _, change := range changes.Changes {
+		got[key{change.Kind, change.ID}] = change
+	}
+
+	want := []key{
+		{ChangeRenamed, "1"},
+		{ChangeAdded, "14"},
+		{ChangeReordered, "10"},
+		{ChangeRenamed, "11"},
+		{ChangeMoved, "11"},
+		{ChangeReordered, "11"},
+		{ChangeReordered, "12"},
+		{ChangeModified, "12"},
+		{ChangeRemoved, "13"},
+	}
+	if len(changes.Changes) != len(want) {
+		t.Errorf("got %d changes, want %d: %+v", len(changes.Changes), len(want), changes.Changes)
+	}
+	for _, k := range want {
+		if _, ok := got[k]; !ok {
+			t.Errorf("missing %s change for %s", k.kind, k.id)
+		}
+	}
+
+	modified := got[key{ChangeModified, "12"}]
+	if len(modified.Fields) != 1 || modified.Fields[0] != (FieldChange{Field: "stream_icon", Old: "old.png", New: "new.png"}) {
+		t.Errorf("modified fields = %+v", modified.Fields)
+	}
+
+	reordered := got[key{ChangeReordered, "10"}]
+	if len(reordered.Fields) != 1 || reordered.Fields[0] != (FieldChange{Field: "num", Old: "1", New: "2"}) {
+		t.Errorf("reordered fields = %+v", reordered.Fields)
+	}
+
+	moved := got[key{ChangeMoved, "11"}]
+	if moved.OldCategoryID != "1" || moved.CategoryID != "2" {
+		t.Errorf("moved = %+v", moved)
+	}
+
+	if n := len(changes.Filter(ChangeModified)); n != 1 {
+		t.Errorf("Filter(ChangeModified) = %d changes, want 1", n)
+	}
+	if n := len(changes.Filter(ChangeRenamed, EntityLiveCategory)); n != 1 {
+		t.Errorf("Filter(ChangeRenamed, EntityLiveCategory) = %d changes, want 1", n)
+	}
+
+	if !DiffCatalogs(old, old).Empty() {
+		t.Error("diff of a catalog with itself is not empty")
+	}
+}
@@ -1,6 +1,12 @@
 package iptv
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
 
 var (
 	// ErrInvalidCredentials is returned when username or password is empty
@@ -9,9 +15,75 @@ var (
 	// ErrInvalidBaseURL is returned when base URL is empty
 	ErrInvalidBaseURL = errors.New("base URL is required")
 
+	// ErrMissingURLBuilder is returned when an M3U writer has no way to build stream URLs
+	ErrMissingURLBuilder = errors.New("URL builder or proxy base URL is required")
+
+	// ErrStreamNotFound is returned when a stream ID is unknown
+	ErrStreamNotFound = errors.New("stream not found")
+
+	// ErrCatchupUnavailable is returned when no catch-up URL can be built for a programme
+	ErrCatchupUnavailable = errors.New("catch-up unavailable")
+
+	// ErrInvalidQuery is returned when a filter query cannot be parsed
+	ErrInvalidQuery = errors.New("invalid query")
+
+	// ErrUnknownField is returned when a filter or sort key is not supported by a model
+	ErrUnknownField = errors.New("unknown field")
+
+	// ErrInvalidPIN is returned when a parental control PIN does not match
+	ErrInvalidPIN = errors.New("invalid parental control PIN")
+
+	// ErrUnsupportedCatalog is returned when a saved catalog has an unknown version
+	ErrUnsupportedCatalog = errors.New("unsupported catalog version")
+
+	// ErrNotInSnapshot is returned when a catalog snapshot lacks the requested data
+	ErrNotInSnapshot = errors.New("not available in snapshot")
+
+	// ErrNoCapacity is returned when every account of a pool is at its connection limit
+	ErrNoCapacity = errors.New("no account with free connections")
+
+	// ErrWatcherStarted is returned when a catalog watcher is run twice
+	ErrWatcherStarted = errors.New("catalog watcher already started")
+
+	// ErrCircuitOpen is returned without contacting the provider while the
+	// circuit breaker of every host is open
+	ErrCircuitOpen = errors.New("circuit breaker open")
+
 	// ErrRateLimitExceeded is returned when rate limit is exceeded
 	ErrRateLimitExceeded = errors.New("rate limit exceeded")
 
 	// ErrRequestFailed is returned when request fails
 	ErrRequestFailed = errors.New("request failed")
 )
+
+// statusError is returned when the panel answers with an unexpected HTTP
+// status code
+type statusError struct {
+	code int
+	// throttled is set when the provider asked to slow down, which is not
+	// a failure of the host
+	throttled bool
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.code)
+}
+
+// isUnavailable reports whether an error means that the provider could not
+// be reached or failed, as opposed to a cancelled or invalid request
+func isUnavailable(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+
+	var status *statusError
+	if errors.As(err, &status) {
+		return status.code >= http.StatusInternalServerError || status.code == http.StatusTooManyRequests
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,238 @@
+package iptv
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// schema describes the filter and sort keys of a model. Keys come from the
+// `query` struct tags of the model, e.g. `query:"stream_id,id"`, and from
+// derived accessors for computed or fallback values. Every model goes through
+// the same filtering, sorting and pagination code.
+type schema[T any] struct {
+	fields map[string]func(*T) string
+	raw    []func(*T) string
+
+	// sortValues overrides the value used for sorting by some keys
+	sortValues map[string]func(*T) string
+}
+
+// newSchema builds a schema from the `query` tags of T. Derived accessors
+// override tagged fields with the same key.
+func newSchema[T any](derived map[string]func(*T) string) *schema[T] {
+	s := &schema[T]{fields: map[string]func(*T) string{}}
+
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("query")
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		accessor := fieldAccessor[T](field.Index)
+		s.raw = append(s.raw, accessor)
+		for _, key := range strings.Split(tag, ",") {
+			s.fields[key] = accessor
+		}
+	}
+
+	for key, accessor := range derived {
+		s.fields[key] = accessor
+	}
+
+	return s
+}
+
+// withSortValues sets accessors that replace the plain value of some keys
+// when sorting, e.g. a rank for quality tags
+func (s *schema[T]) withSortValues(values map[string]func(*T) string) *schema[T] {
+	s.sortValues = values
+	return s
+}
+
+// fieldAccessor returns a function formatting a struct field as a string
+func fieldAccessor[T any](index []int) func(*T) string {
+	return func(item *T) string {
+		v := reflect.ValueOf(item).Elem().FieldByIndex(index)
+		switch v.Kind() {
+		case reflect.String:
+			return v.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return strconv.FormatInt(v.Int(), 10)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return strconv.FormatUint(v.Uint(), 10)
+		case reflect.Float32, reflect.Float64:
+			return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+		case reflect.Bool:
+			return strconv.FormatBool(v.Bool())
+		default:
+			return fmt.Sprint(v.Interface())
+		}
+	}
+}
+
+// keyNames returns the sorted list of keys known to the schema
+func (s *schema[T]) keyNames() []string {
+	keys := make([]string, 0, len(s.fields))
+	for key := range s.fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// get returns the value of a key. Keys are validated before use, so unknown
+// keys yield an empty string.
+func (s *schema[T]) get(item *T, key string) string {
+	if key == RawKey {
+		values := make([]string, len(s.raw))
+		for i, accessor := range s.raw {
+			values[i] = accessor(item)
+		}
+		return strings.Join(values, "|")
+	}
+
+	if accessor, ok := s.fields[strings.ToLower(key)]; ok {
+		return accessor(item)
+	}
+	return ""
+}
+
+// sortValue returns the value used when sorting by a key
+func (s *schema[T]) sortValue(item *T, key string) string {
+	if accessor, ok := s.sortValues[strings.ToLower(key)]; ok {
+		return accessor(item)
+	}
+	return s.get(item, key)
+}
+
+// validate checks that every key used by the query and sort keys is known
+func (s *schema[T]) validate(query Query, keys []SortKey) error {
+	check := func(key string) error {
+		if key == RawKey {
+			return nil
+		}
+		if _, ok := s.fields[strings.ToLower(key)]; !ok {
+			return fmt.Errorf("%w: %q", ErrUnknownField, key)
+		}
+		return nil
+	}
+
+	if query != nil {
+		var err error
+		query.walk(func(p *Predicate) {
+			if err == nil {
+				err = check(p.Key)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, key := range keys {
+		if err := check(key.Key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// apply filters, sorts and paginates items according to the request options
+func (s *schema[T]) apply(items []T, options *RequestOptions) ([]T, error) {
+	query, err := options.query()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := options.sortKeys()
+	if err := s.validate(query, keys); err != nil {
+		return nil, err
+	}
+
+	result := items
+
+	// Apply filtering if specified
+	if query != nil {
+		filtered := make([]T, 0)
+		for i := range result {
+			item := &result[i]
+			if query.eval(func(key string) string { return s.get(item, key) }) {
+				filtered = append(filtered, *item)
+			}
+		}
+		result = filtered
+	}
+
+	// Apply sorting if specified
+	result = sortByKeys(result, keys, s.sortValue)
+
+	return paginate(result, options), nil
+}
+
+// streamSchema defines the filter and sort keys of streams
+var streamSchema = newSchema(map[string]func(*Stream) string{
+	// M3U attributes fall back to the stream name when not available
+	"group-title": func(s *Stream) string { return firstNonEmpty(s.GroupTitle, s.Name) },
+	"tvg-name":    func(s *Stream) string { return firstNonEmpty(s.TVGName, s.Name) },
+
+	// Metadata parsed from the channel name
+	"base-name": func(s *Stream) string { return s.ChannelName().Base },
+	"country":   func(s *Stream) string { return s.ChannelName().Country },
+	"language":  func(s *Stream) string { return s.ChannelName().Language },
+	"quality":   func(s *Stream) string { return s.ChannelName().Quality },
+	"codec":     func(s *Stream) string { return s.ChannelName().Codec },
+	"backup":    func(s *Stream) string { return strconv.FormatBool(s.ChannelName().Backup) },
+
+	// Metadata parsed from VOD titles
+	"title":      func(s *Stream) string { return s.MediaTitle().Title },
+	"year":       func(s *Stream) string { return formatYear(s.MediaTitle().Year) },
+	"resolution": func(s *Stream) string { return s.MediaTitle().Resolution },
+}).withSortValues(map[string]func(*Stream) string{
+	// Quality and resolution sort by rank rather than alphabetically
+	"quality":    func(s *Stream) string { return strconv.Itoa(QualityRank(s.ChannelName().Quality)) },
+	"resolution": func(s *Stream) string { return strconv.Itoa(ResolutionRank(s.MediaTitle().Resolution)) },
+})
+
+// categorySchema defines the filter and sort keys of categories
+var categorySchema = newSchema(map[string]func(*Category) string{
+	// A category is the group-title of its streams in M3U terms
+	"group-title": func(c *Category) string { return c.Name },
+})
+
+// seriesSchema defines the filter and sort keys of series
+var seriesSchema = newSchema(map[string]func(*Series) string{
+	"title":      func(s *Series) string { return s.MediaTitle().Title },
+	"year":       func(s *Series) string { return formatYear(s.MediaTitle().Year) },
+	"resolution": func(s *Series) string { return s.MediaTitle().Resolution },
+}).withSortValues(map[string]func(*Series) string{
+	"resolution": func(s *Series) string { return strconv.Itoa(ResolutionRank(s.MediaTitle().Resolution)) },
+})
+
+// formatYear formats a year key, empty when the year is unknown
+func formatYear(year int) string {
+	if year == 0 {
+		return ""
+	}
+	return strconv.Itoa(year)
+}
+
+// StreamKeys returns the filter and sort keys supported for streams
+func StreamKeys() []string {
+	return streamSchema.keyNames()
+}
+
+// CategoryKeys returns the filter and sort keys supported for categories
+func CategoryKeys() []string {
+	return categorySchema.keyNames()
+}
+
+// SeriesKeys returns the filter and sort keys supported for series
+func SeriesKeys() []string {
+	return seriesSchema.keyNames()
+}
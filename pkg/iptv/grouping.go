@@ -0,0 +1,165 @@
+package iptv
+
+import (
+	"sort"
+	"strings"
+)
+
+// DefaultQualityPreference prefers the best quality first
+var DefaultQualityPreference = []string{"8K", "UHD", "4K", "FHD", "HD", "SD"}
+
+// GroupOptions configures GroupChannels
+type GroupOptions struct {
+	// QualityPreference lists quality tags from most to least preferred.
+	// Variants with a quality not in the list come last. Defaults to
+	// DefaultQualityPreference.
+	QualityPreference []string
+
+	// IgnoreCountry groups variants with different country prefixes together
+	IgnoreCountry bool
+}
+
+// ChannelGroup is one logical channel and all the variants a provider lists
+// for it
+type ChannelGroup struct {
+	// Key is the normalised identity of the channel
+	Key string
+	// Name is the clean base name of the channel
+	Name         string
+	Country      string
+	EPGChannelID string
+
+	// Preferred is the best variant according to the quality preference
+	Preferred Stream
+	// Fallbacks holds the remaining variants in order of preference
+	Fallbacks []Stream
+}
+
+// Variants returns the preferred variant followed by the fallbacks
+func (g ChannelGroup) Variants() []Stream {
+	return append([]Stream{g.Preferred}, g.Fallbacks...)
+}
+
+// GroupChannels clusters streams into logical channels by normalised base
+// name and epg_channel_id. Variants with conflicting EPG IDs stay apart,
+// variants without an EPG ID join the largest cluster of their name. Groups
+// are returned in order of first appearance.
+func GroupChannels(streams []Stream, opts GroupOptions) []ChannelGroup {
+	preference := opts.QualityPreference
+	if len(preference) == 0 {
+		preference = DefaultQualityPreference
+	}
+
+	type variant struct {
+		stream Stream
+		name   ChannelName
+		order  int
+	}
+
+	// Cluster by normalised name first
+	var names []string
+	byName := map[string][]variant{}
+	for i, stream := range streams {
+		name := stream.ChannelName()
+		key := strings.Join(searchTokens(name.Base), " ")
+		if key == "" {
+			key = strings.Join(searchTokens(stream.Name), " ")
+		}
+		if !opts.IgnoreCountry && name.Country != "" {
+			key = strings.ToLower(name.Country) + ":" + key
+		}
+
+		if _, ok := byName[key]; !ok {
+			names = append(names, key)
+		}
+		byName[key] = append(byName[key], variant{stream: stream, name: name, order: i})
+	}
+
+	var groups []ChannelGroup
+	for _, key := range names {
+		variants := byName[key]
+
+		// Split clusters whose variants carry different EPG IDs
+		var epgIDs []string
+		byEPG := map[string][]variant{}
+		var unassigned []variant
+		for _, v := range variants {
+			id := strings.ToLower(v.stream.EPGChannelID)
+			if id == "" {
+				unassigned = append(unassigned, v)
+				continue
+			}
+			if _, ok := byEPG[id]; !ok {
+				epgIDs = append(epgIDs, id)
+			}
+			byEPG[id] = append(byEPG[id], v)
+		}
+
+		if len(epgIDs) == 0 {
+			epgIDs = []string{""}
+		}
+		largest := epgIDs[0]
+		for _, id := range epgIDs {
+			if len(byEPG[id]) > len(byEPG[largest]) {
+				largest = id
+			}
+		}
+		byEPG[largest] = append(byEPG[largest], unassigned...)
+
+		for _, id := range epgIDs {
+			cluster := byEPG[id]
+			sort.SliceStable(cluster, func(i, j int) bool {
+				a, b := cluster[i], cluster[j]
+				if a.name.Backup != b.name.Backup {
+					return !a.name.Backup
+				}
+				if pa, pb := qualityIndex(preference, a.name.Quality), qualityIndex(preference, b.name.Quality); pa != pb {
+					return pa < pb
+				}
+				return a.order < b.order
+			})
+
+			groupKey := key
+			if len(epgIDs) > 1 {
+				groupKey += "#" + id
+			}
+
+			group := ChannelGroup{
+				Key:       groupKey,
+				Name:      firstNonEmpty(cluster[0].name.Base, cluster[0].stream.Name),
+				Country:   cluster[0].name.Country,
+				Preferred: cluster[0].stream,
+			}
+			for _, v := range cluster {
+				group.EPGChannelID = firstNonEmpty(group.EPGChannelID, v.stream.EPGChannelID)
+			}
+			for _, v := range cluster[1:] {
+				group.Fallbacks = append(group.Fallbacks, v.stream)
+			}
+			groups = append(groups, group)
+		}
+	}
+
+	return groups
+}
+
+// qualityIndex returns the position of a quality tag in the preference list,
+// or the length of the list for unknown tags
+func qualityIndex(preference []string, quality string) int {
+	for i, q := range preference {
+		if strings.EqualFold(q, quality) {
+			return i
+		}
+	}
+	return len(preference)
+}
+
+// Lineup returns the preferred variant of every group, a de-duplicated list
+// of channels that can be written out with WriteM3U
+func Lineup(groups []ChannelGroup) []Stream {
+	streams := make([]Stream, 0, len(groups))
+	for _, group := range groups {
+		streams = append(streams, group.Preferred)
+	}
+	return streams
+}
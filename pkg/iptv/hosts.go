@@ -0,0 +1,215 @@
+package iptv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultHostCooldown is how long a host that failed is tried only after
+// the healthy ones
+const defaultHostCooldown = 30 * time.Second
+
+// hostPool tracks the health of the base URLs of one panel. Without a
+// circuit breaker, failing hosts are only tried last during their cooldown.
+// With one, a host whose circuit is open is not tried at all.
+type hostPool struct {
+	mu        sync.Mutex
+	hosts     []*host
+	preferred *host
+	cooldown  time.Duration
+
+	// Circuit breaker, disabled when threshold is 0
+	threshold int
+	onChange  func(baseURL string, from, to CircuitState)
+}
+
+type host struct {
+	baseURL  string
+	retryAt  time.Time
+	state    CircuitState
+	failures int  // consecutive
+	probing  bool // a half-open trial request is in flight
+}
+
+// newHostPool creates a pool over base URLs in priority order, ignoring
+// empty and duplicate entries
+func newHostPool(baseURLs []string) *hostPool {
+	p := &hostPool{cooldown: defaultHostCooldown}
+	seen := map[string]bool{}
+	for _, baseURL := range baseURLs {
+		baseURL = strings.TrimRight(baseURL, "/")
+		if baseURL == "" || seen[baseURL] {
+			continue
+		}
+		seen[baseURL] = true
+		p.hosts = append(p.hosts, &host{baseURL: baseURL})
+	}
+	return p
+}
+
+// order returns the hosts in the order they should be tried: the host that
+// last succeeded, then the others in priority order. Hosts cooling down
+// after a failure come last, so that they are still tried when every host
+// failed recently.
+func (p *hostPool) order() []*host {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	healthy := make([]*host, 0, len(p.hosts))
+	var cooling []*host
+	if p.preferred != nil && p.preferred.healthy(now) {
+		healthy = append(healthy, p.preferred)
+	}
+	for _, h := range p.hosts {
+		if !h.healthy(now) {
+			cooling = append(cooling, h)
+		} else if h != p.preferred {
+			healthy = append(healthy, h)
+		}
+	}
+	return append(healthy, cooling...)
+}
+
+func (h *host) healthy(now time.Time) bool {
+	return h.state == CircuitClosed && !now.Before(h.retryAt)
+}
+
+// allow reports whether a request may be sent to a host. An open circuit
+// lets one trial request through once its cooldown has passed.
+func (p *hostPool) allow(h *host) bool {
+	p.mu.Lock()
+	var notify func()
+	switch {
+	case h.state == CircuitClosed:
+	case h.state == CircuitOpen && !time.Now().Before(h.retryAt):
+		notify = p.transition(h, CircuitHalfOpen)
+		h.probing = true
+	case h.state == CircuitHalfOpen && !h.probing:
+		h.probing = true
+	default:
+		p.mu.Unlock()
+		return false
+	}
+	p.mu.Unlock()
+
+	if notify != nil {
+		notify()
+	}
+	return true
+}
+
+// succeeded closes the circuit of a host and prefers it for the next
+// requests
+func (p *hostPool) succeeded(h *host) {
+	p.mu.Lock()
+	h.retryAt = time.Time{}
+	h.failures = 0
+	h.probing = false
+	p.preferred = h
+	notify := p.transition(h, CircuitClosed)
+	p.mu.Unlock()
+
+	if notify != nil {
+		notify()
+	}
+}
+
+// failed puts a host on cooldown and opens its circuit once the failure
+// threshold is reached or its trial request failed
+func (p *hostPool) failed(h *host) {
+	p.mu.Lock()
+	h.retryAt = time.Now().Add(p.cooldown)
+	h.failures++
+	h.probing = false
+	var notify func()
+	if p.threshold > 0 && (h.failures >= p.threshold || h.state == CircuitHalfOpen) {
+		notify = p.transition(h, CircuitOpen)
+	}
+	p.mu.Unlock()
+
+	if notify != nil {
+		notify()
+	}
+}
+
+// released ends a request whose outcome says nothing about the host, e.g.
+// because it was cancelled
+func (p *hostPool) released(h *host) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	h.probing = false
+}
+
+// transition changes the circuit state of a host and returns the hook call
+// to make once the pool is unlocked. Must be called with p.mu held.
+func (p *hostPool) transition(h *host, to CircuitState) func() {
+	from := h.state
+	h.state = to
+	if from == to || p.onChange == nil {
+		return nil
+	}
+	return func() { p.onChange(h.baseURL, from, to) }
+}
+
+// openError returns the error for a request that no host would accept
+func (p *hostPool) openError() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var next time.Time
+	for _, h := range p.hosts {
+		if h.state == CircuitOpen && (next.IsZero() || h.retryAt.Before(next)) {
+			next = h.retryAt
+		}
+	}
+	if wait := time.Until(next); wait > 0 {
+		return fmt.Errorf("%w: retry in %s", ErrCircuitOpen, wait.Round(time.Millisecond))
+	}
+	return ErrCircuitOpen
+}
+
+// baseURLs returns the base URLs in the order they would be tried
+func (p *hostPool) baseURLs() []string {
+	hosts := p.order()
+	urls := make([]string, len(hosts))
+	for i, h := range hosts {
+		urls[i] = h.baseURL
+	}
+	return urls
+}
+
+// states returns the circuit state of every host
+func (p *hostPool) states() map[string]CircuitState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	states := make(map[string]CircuitState, len(p.hosts))
+	for _, h := range p.hosts {
+		states[h.baseURL] = h.state
+	}
+	return states
+}
+
+// isHostFailure reports whether an error is the host's fault, so that the
+// request should be retried on another host. Rate limiting applies to the
+// account and is not a host failure, including a 503 with Retry-After.
+func isHostFailure(ctx context.Context, err error) bool {
+	var status *statusError
+	if errors.As(err, &status) && (status.code == http.StatusTooManyRequests || status.throttled) {
+		return false
+	}
+	return isUnavailable(ctx, err)
+}
+
+// Hosts returns the base URLs of the client in the order requests try them:
+// the host that last succeeded first and hosts that recently failed last
+func (c *Client) Hosts() []string {
+	return c.hosts.baseURLs()
+}
@@ -0,0 +1,112 @@
+package iptv
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// failingHandler answers every request with a status code
+func failingHandler(code int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, http.StatusText(code), code)
+	}
+}
+
+func TestMirrorFailover(t *testing.T) {
+	tests := []struct {
+		name    string
+		primary http.HandlerFunc
+		// failover is false when the error is the request's, not the host's
+		failover bool
+	}{
+		{"server error", failingHandler(http.StatusBadGateway), true},
+		{"unavailable", failingHandler(http.StatusServiceUnavailable), true},
+		{"not found", failingHandler(http.StatusNotFound), false},
+		{"forbidden", failingHandler(http.StatusForbidden), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := newTestPanel(t, nil)
+			primary.handle("get_live_streams", tt.primary)
+			mirror := newTestPanel(t, map[string]any{
+				"get_live_streams": []Stream{{ID: 1, Name: "News One"}},
+			})
+			client := newTestClientWithConfig(t, &Config{BaseURL: primary.URL, BaseURLs: []string{mirror.URL}})
+			ctx := context.Background()
+
+			streams, err := client.StreamService().GetLive(ctx)
+			if !tt.failover {
+				var status *statusError
+				if !errors.As(err, &status) {
+					t.Errorf("GetLive() = %+v, %v, want a status error", streams, err)
+				}
+				if n := mirror.count("get_live_streams"); n != 0 {
+					t.Errorf("mirror got %d requests, want 0", n)
+				}
+				return
+			}
+
+			if err != nil || len(streams) != 1 {
+				t.Fatalf("GetLive() = %+v, %v", streams, err)
+			}
+			if hosts := client.Hosts(); !slices.Equal(hosts, []string{mirror.URL, primary.URL}) {
+				t.Errorf("Hosts() = %q, want the mirror first", hosts)
+			}
+
+			// The mirror is preferred while the primary cools down
+			if _, err := client.StreamService().GetLive(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if n := primary.count("get_live_streams"); n != 1 {
+				t.Errorf("primary got %d requests, want 1", n)
+			}
+			if n := mirror.count("get_live_streams"); n != 2 {
+				t.Errorf("mirror got %d requests, want 2", n)
+			}
+		})
+	}
+}
+
+func TestMirrorFailoverUnreachable(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	mirror := newTestPanel(t, map[string]any{
+		"get_live_streams": []Stream{{ID: 1, Name: "News One"}},
+	})
+	client := newTestClientWithConfig(t, &Config{BaseURL: down.URL, BaseURLs: []string{mirror.URL}})
+
+	streams, err := client.StreamService().GetLive(context.Background())
+	if err != nil || len(streams) != 1 {
+		t.Fatalf("GetLive() = %+v, %v", streams, err)
+	}
+	if base := client.BaseURL(); base != mirror.URL {
+		t.Errorf("BaseURL() = %q, want the mirror", base)
+	}
+}
+
+func TestMirrorFailoverAllDown(t *testing.T) {
+	primary := newTestPanel(t, nil)
+	primary.handle("get_live_streams", failingHandler(http.StatusBadGateway))
+	mirror := newTestPanel(t, nil)
+	mirror.handle("get_live_streams", failingHandler(http.StatusInternalServerError))
+	client := newTestClientWithConfig(t, &Config{BaseURL: primary.URL, BaseURLs: []string{mirror.URL}})
+
+	_, err := client.StreamService().GetLive(context.Background())
+	var status *statusError
+	if !errors.As(err, &status) || status.code != http.StatusInternalServerError {
+		t.Errorf("GetLive() = %v, want the mirror's status", err)
+	}
+
+	// Hosts cooling down are still tried when no other host is left
+	if _, err := client.StreamService().GetLive(context.Background()); err == nil {
+		t.Error("second GetLive() succeeded")
+	}
+	if n := primary.count("get_live_streams"); n != 2 {
+		t.Errorf("primary got %d requests, want 2", n)
+	}
+}
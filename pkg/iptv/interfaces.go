@@ -18,6 +18,12 @@ type CategoryService interface {
 	GetSeriesCategories(ctx context.Context, opts ...RequestOption) ([]Category, error)
 }
 
+// SeriesService handles all series-related operations
+type SeriesService interface {
+	GetSeries(ctx context.Context, opts ...RequestOption) ([]Series, error)
+	GetEpisodes(ctx context.Context, seriesID int) ([]Episode, error)
+}
+
 // EPGService handles all EPG-related operations
 type EPGService interface {
 	GetShortEPG(ctx context.Context, streamID string, limit int) ([]EPGInfo, error)
@@ -25,6 +31,23 @@ type EPGService interface {
 	GetXMLTV(ctx context.Context) ([]byte, error)
 }
 
+// EPGRequestService is an EPGService whose calls also take request options.
+// Of the request options, only WithStale and WithCacheBypass apply to EPG
+// calls. The client's EPG service implements it:
+//
+//	epg := client.EPGService().(iptv.EPGRequestService)
+type EPGRequestService interface {
+	EPGService
+	GetShortEPGWithOptions(ctx context.Context, streamID string, limit int, opts ...RequestOption) ([]EPGInfo, error)
+	GetFullEPGWithOptions(ctx context.Context, streamID string, opts ...RequestOption) ([]EPGInfo, error)
+	GetXMLTVWithOptions(ctx context.Context, opts ...RequestOption) ([]byte, error)
+}
+
+// PlaylistService handles the provider's M3U playlist (get.php)
+type PlaylistService interface {
+	GetPlaylist(ctx context.Context, output string) (*Playlist, error)
+}
+
 // RequestOption defines options for API requests
 type RequestOption func(*RequestOptions)
 
@@ -41,10 +64,30 @@ const (
 // RequestOptions contains all possible options for API requests
 type RequestOptions struct {
 	CategoryID string
-	Limit      int
-	Filter     string
-	FilterKey  string
-	FilterRaw  bool
-	Sort       string
-	SortDir    SortDirection
+	// CategoryIDs and CategoryName select several categories, see
+	// WithCategoryIDs and WithCategoryName
+	CategoryIDs  []string
+	CategoryName string
+	Limit        int
+	Offset       int
+	Filter       string
+	FilterKey    string
+	FilterRaw    bool
+	Sort         string
+	SortDir      SortDirection
+	SortKeys     []SortKey
+
+	// Query holds the combined filter. WithFilter, WithFilterRaw and WithQuery
+	// all add to it; Filter/FilterKey/FilterRaw are still honoured when set
+	// directly.
+	Query Query
+
+	// PIN unlocks adult content when the client has parental control enabled
+	PIN string
+
+	total       *int
+	stale       *bool
+	err         error
+	unlocked    bool
+	bypassCache bool
 }
@@ -0,0 +1,465 @@
+package iptv
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// M3UConfig configures an M3UWriter
+type M3UConfig struct {
+	// EPGURL is written as the x-tvg-url attribute of the #EXTM3U header
+	EPGURL string
+
+	// URLBuilder builds the entry URLs. Required unless ProxyBaseURL is set.
+	URLBuilder *URLBuilder
+
+	// ProxyBaseURL replaces the provider host and leaves the credentials
+	// out of every entry URL
+	ProxyBaseURL string
+
+	// Format is the extension used for live streams (default "ts").
+	// VOD streams always use their container extension.
+	Format string
+
+	// NameTemplate is a text/template executed with the Stream to build the
+	// display name of each entry (default "{{.Name}}")
+	NameTemplate string
+
+	// CategoryNames maps category IDs to group titles for streams that have
+	// no GroupTitle of their own
+	CategoryNames map[string]string
+}
+
+// M3UWriter writes streams as an extended M3U playlist
+type M3UWriter struct {
+	w           io.Writer
+	cfg         M3UConfig
+	urls        *URLBuilder
+	name        *template.Template
+	wroteHeader bool
+}
+
+// NewM3UWriter creates a new M3U writer
+func NewM3UWriter(w io.Writer, cfg M3UConfig) (*M3UWriter, error) {
+	urls := cfg.URLBuilder
+	if cfg.ProxyBaseURL != "" {
+		urls = &URLBuilder{BaseURL: cfg.ProxyBaseURL}
+	}
+	if urls == nil {
+		return nil, ErrMissingURLBuilder
+	}
+
+	writer := &M3UWriter{w: w, cfg: cfg, urls: urls}
+
+	if cfg.NameTemplate != "" {
+		tmpl, err := template.New("name").Parse(cfg.NameTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid name template: %w", err)
+		}
+		writer.name = tmpl
+	}
+
+	return writer, nil
+}
+
+// WriteHeader writes the #EXTM3U header line. It is called automatically
+// by Write when needed.
+func (m *M3UWriter) WriteHeader() error {
+	if m.wroteHeader {
+		return nil
+	}
+	m.wroteHeader = true
+
+	header := "#EXTM3U"
+	if m.cfg.EPGURL != "" {
+		header += fmt.Sprintf(` x-tvg-url="%s"`, m3uEscape(m.cfg.EPGURL))
+	}
+
+	_, err := fmt.Fprintln(m.w, header)
+	return err
+}
+
+// Write writes a single stream entry
+func (m *M3UWriter) Write(stream Stream) error {
+	if err := m.WriteHeader(); err != nil {
+		return err
+	}
+
+	name, err := m.displayName(stream)
+	if err != nil {
+		return err
+	}
+
+	var attrs []string
+	attr := func(key, value string) {
+		if value != "" {
+			attrs = append(attrs, fmt.Sprintf(`%s="%s"`, key, m3uEscape(value)))
+		}
+	}
+
+	attr("tvg-id", firstNonEmpty(stream.TVGID, stream.EPGChannelID))
+	attr("tvg-name", firstNonEmpty(stream.TVGName, stream.Name))
+	attr("tvg-logo", firstNonEmpty(stream.TVGLogo, stream.StreamIcon))
+	if stream.Num > 0 {
+		attr("tvg-chno", fmt.Sprintf("%d", stream.Num))
+	}
+	attr("group-title", firstNonEmpty(stream.GroupTitle, m.cfg.CategoryNames[stream.CategoryID]))
+	if mode := stream.catchupMode(); mode != "" {
+		attr("catchup", mode)
+		attr("catchup-source", stream.CatchupSource)
+		if days := stream.catchupDays(); days > 0 {
+			attr("catchup-days", strconv.Itoa(days))
+		}
+	}
+	if stream.TVGShift != 0 {
+		attr("tvg-shift", strconv.FormatFloat(stream.TVGShift, 'f', -1, 64))
+	}
+
+	format := ""
+	if streamPath(stream.Type) == "live" {
+		format = m.cfg.Format
+	}
+
+	extinf := "#EXTINF:-1"
+	if len(attrs) > 0 {
+		extinf += " " + strings.Join(attrs, " ")
+	}
+
+	_, err = fmt.Fprintf(m.w, "%s,%s\n%s\n", extinf, name, m.urls.StreamURL(stream, format))
+	return err
+}
+
+// WriteAll writes the header followed by every stream
+func (m *M3UWriter) WriteAll(streams []Stream) error {
+	if err := m.WriteHeader(); err != nil {
+		return err
+	}
+
+	for _, stream := range streams {
+		if err := m.Write(stream); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteM3U writes streams to w as an extended M3U playlist
+func WriteM3U(w io.Writer, streams []Stream, cfg M3UConfig) error {
+	writer, err := NewM3UWriter(w, cfg)
+	if err != nil {
+		return err
+	}
+
+	return writer.WriteAll(streams)
+}
+
+func (m *M3UWriter) displayName(stream Stream) (string, error) {
+	if m.name == nil {
+		return m3uTitle(stream.Name), nil
+	}
+
+	var buf bytes.Buffer
+	if err := m.name.Execute(&buf, stream); err != nil {
+		return "", fmt.Errorf("error executing name template: %w", err)
+	}
+
+	return m3uTitle(buf.String()), nil
+}
+
+// m3uEscape makes a value safe to use inside a quoted attribute
+func m3uEscape(value string) string {
+	return strings.NewReplacer(`"`, "'", "\n", " ", "\r", " ").Replace(value)
+}
+
+// m3uTitle makes a value safe to use as the title of an #EXTINF line
+func m3uTitle(value string) string {
+	return strings.TrimSpace(strings.NewReplacer("\n", " ", "\r", " ").Replace(value))
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Playlist is a parsed M3U playlist
+type Playlist struct {
+	// Attributes holds the attributes of the #EXTM3U header, such as x-tvg-url
+	Attributes map[string]string
+	Entries    []PlaylistEntry
+}
+
+// PlaylistEntry is a single entry of an M3U playlist
+type PlaylistEntry struct {
+	Duration   float64
+	Name       string
+	Attributes map[string]string
+	URL        string
+
+	// StreamID and StreamType are parsed from Xtream style URLs such as
+	// /live/user/pass/123.ts. StreamID is 0 when the URL has no numeric ID.
+	StreamID   int
+	StreamType string
+}
+
+// ParseM3U parses an extended M3U playlist
+func ParseM3U(r io.Reader) (*Playlist, error) {
+	playlist := &Playlist{Attributes: map[string]string{}}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var pending *PlaylistEntry
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if lineNo == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#EXTM3U"):
+			attrs, _ := parseM3UAttributes(strings.TrimPrefix(line, "#EXTM3U"))
+			for k, v := range attrs {
+				playlist.Attributes[k] = v
+			}
+		case strings.HasPrefix(line, "#EXTINF:"):
+			pending = parseExtInf(strings.TrimPrefix(line, "#EXTINF:"))
+		case strings.HasPrefix(line, "#EXTGRP:"):
+			if pending != nil && pending.Attributes["group-title"] == "" {
+				pending.Attributes["group-title"] = strings.TrimSpace(strings.TrimPrefix(line, "#EXTGRP:"))
+			}
+		case strings.HasPrefix(line, "#"):
+			// Other directives (#EXTVLCOPT, #KODIPROP, ...) are ignored
+			continue
+		default:
+			if pending == nil {
+				pending = &PlaylistEntry{Duration: -1, Attributes: map[string]string{}}
+			}
+			pending.URL = line
+			pending.StreamType, pending.StreamID = parseStreamURL(line)
+			if pending.Name == "" {
+				pending.Name = line
+			}
+			playlist.Entries = append(playlist.Entries, *pending)
+			pending = nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading playlist: %w", err)
+	}
+
+	return playlist, nil
+}
+
+// parseExtInf parses the part of an #EXTINF line after the colon. A missing
+// or malformed duration is read as -1 rather than rejecting the playlist.
+func parseExtInf(value string) *PlaylistEntry {
+	value = strings.TrimSpace(value)
+	end := strings.IndexAny(value, " ,")
+	if end < 0 {
+		end = len(value)
+	}
+
+	duration, err := strconv.ParseFloat(value[:end], 64)
+	if err != nil {
+		// Leave the token to the attribute parser, it may be tvg-id=...
+		duration, end = -1, 0
+	}
+
+	attrs, rest := parseM3UAttributes(value[end:])
+	return &PlaylistEntry{
+		Duration:   duration,
+		Name:       strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest), ",")),
+		Attributes: attrs,
+	}
+}
+
+// parseM3UAttributes parses key="value" pairs up to the first comma outside
+// quotes and returns the attributes and the remainder of the line
+func parseM3UAttributes(s string) (map[string]string, string) {
+	attrs := map[string]string{}
+
+	i := 0
+	for i < len(s) {
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+		if i >= len(s) || s[i] == ',' {
+			break
+		}
+
+		eq := strings.IndexAny(s[i:], "= ,")
+		if eq < 0 || s[i+eq] != '=' {
+			// Bare word without a value
+			next := strings.IndexAny(s[i:], " ,")
+			if next < 0 {
+				return attrs, ""
+			}
+			i += next
+			continue
+		}
+
+		key := strings.ToLower(s[i : i+eq])
+		i += eq + 1
+
+		var value string
+		if i < len(s) && s[i] == '"' {
+			closing := strings.IndexByte(s[i+1:], '"')
+			if closing < 0 {
+				value = s[i+1:]
+				i = len(s)
+			} else {
+				value = s[i+1 : i+1+closing]
+				i += closing + 2
+			}
+		} else {
+			next := strings.IndexAny(s[i:], " ,")
+			if next < 0 {
+				next = len(s) - i
+			}
+			value = s[i : i+next]
+			i += next
+		}
+
+		attrs[key] = value
+	}
+
+	return attrs, s[i:]
+}
+
+// parseStreamURL extracts the stream type and ID from Xtream style URLs
+func parseStreamURL(rawURL string) (string, int) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", 0
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) == 0 {
+		return "", 0
+	}
+
+	last := segments[len(segments)-1]
+	if dot := strings.LastIndexByte(last, '.'); dot >= 0 {
+		last = last[:dot]
+	}
+	id, err := strconv.Atoi(last)
+	if err != nil {
+		return "", 0
+	}
+
+	switch segments[0] {
+	case "movie", "series":
+		return segments[0], id
+	default:
+		return "live", id
+	}
+}
+
+// Stream converts the entry into a Stream
+func (e PlaylistEntry) Stream() Stream {
+	stream := Stream{
+		ID:         e.StreamID,
+		Name:       e.Name,
+		Type:       e.StreamType,
+		TVGID:      e.Attributes["tvg-id"],
+		TVGName:    e.Attributes["tvg-name"],
+		TVGLogo:    e.Attributes["tvg-logo"],
+		GroupTitle: e.Attributes["group-title"],
+	}
+
+	if chno, err := strconv.Atoi(e.Attributes["tvg-chno"]); err == nil {
+		stream.Num = FlexInt(chno)
+	}
+
+	stream.Catchup = strings.ToLower(firstNonEmpty(e.Attributes["catchup"], e.Attributes["catchup-type"]))
+	stream.CatchupSource = e.Attributes["catchup-source"]
+	if days, err := strconv.Atoi(firstNonEmpty(e.Attributes["catchup-days"], e.Attributes["timeshift"])); err == nil {
+		stream.CatchupDays = days
+	}
+	if shift, err := strconv.ParseFloat(e.Attributes["tvg-shift"], 64); err == nil {
+		stream.TVGShift = shift
+	}
+
+	if u, err := url.Parse(e.URL); err == nil {
+		if ext := path.Ext(u.Path); ext != "" {
+			stream.Container = strings.TrimPrefix(ext, ".")
+		}
+	}
+
+	return stream
+}
+
+// Streams converts every entry of the playlist into a Stream
+func (p *Playlist) Streams() []Stream {
+	streams := make([]Stream, 0, len(p.Entries))
+	for _, entry := range p.Entries {
+		streams = append(streams, entry.Stream())
+	}
+	return streams
+}
+
+// Lookup returns the entry for a stream type ("live", "movie" or "series")
+// and stream ID
+func (p *Playlist) Lookup(streamType string, streamID int) (PlaylistEntry, bool) {
+	kind := streamPath(streamType)
+	for _, entry := range p.Entries {
+		if entry.StreamID == streamID && streamPath(entry.StreamType) == kind {
+			return entry, true
+		}
+	}
+	return PlaylistEntry{}, false
+}
+
+// Enrich fills the empty M3U fields of API streams from the playlist entries
+// with the same stream ID. The input slice is not modified.
+func (p *Playlist) Enrich(streams []Stream) []Stream {
+	type key struct {
+		kind string
+		id   int
+	}
+
+	index := make(map[key]PlaylistEntry, len(p.Entries))
+	for _, entry := range p.Entries {
+		if entry.StreamID != 0 {
+			index[key{streamPath(entry.StreamType), entry.StreamID}] = entry
+		}
+	}
+
+	result := make([]Stream, len(streams))
+	for i, stream := range streams {
+		if entry, ok := index[key{streamPath(stream.Type), stream.ID}]; ok {
+			stream.TVGID = firstNonEmpty(stream.TVGID, entry.Attributes["tvg-id"])
+			stream.TVGName = firstNonEmpty(stream.TVGName, entry.Attributes["tvg-name"])
+			stream.TVGLogo = firstNonEmpty(stream.TVGLogo, entry.Attributes["tvg-logo"])
+			stream.GroupTitle = firstNonEmpty(stream.GroupTitle, entry.Attributes["group-title"])
+
+			playlistStream := entry.Stream()
+			stream.Catchup = firstNonEmpty(stream.Catchup, playlistStream.Catchup)
+			stream.CatchupSource = firstNonEmpty(stream.CatchupSource, playlistStream.CatchupSource)
+			if stream.CatchupDays == 0 {
+				stream.CatchupDays = playlistStream.CatchupDays
+			}
+			if stream.TVGShift == 0 {
+				stream.TVGShift = playlistStream.TVGShift
+			}
+		}
+		result[i] = stream
+	}
+
+	return result
+}
@@ -0,0 +1,322 @@
+package iptv
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// M3UClient serves streams and categories from a plain M3U playlist. It
+// implements StreamService and CategoryService so that code written against
+// an Xtream Client works unchanged against playlist sources.
+type M3UClient struct {
+	source     string
+	httpClient *http.Client
+	userAgent  string
+
+	mu         sync.RWMutex
+	loaded     bool
+	streams    []Stream
+	categories []Category
+	urls       map[int]string
+}
+
+var (
+	_ StreamService   = (*M3UClient)(nil)
+	_ CategoryService = (*M3UClient)(nil)
+)
+
+// M3UOption is a function that configures an M3U client
+type M3UOption func(*M3UClient)
+
+// WithM3UHTTPClient sets the HTTP client used to download remote playlists
+func WithM3UHTTPClient(httpClient *http.Client) M3UOption {
+	return func(c *M3UClient) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithM3UUserAgent sets the user agent used to download remote playlists
+func WithM3UUserAgent(userAgent string) M3UOption {
+	return func(c *M3UClient) {
+		c.userAgent = userAgent
+	}
+}
+
+// NewM3UClient creates a client backed by a playlist. The source is either
+// an http(s) URL or a local file path. The playlist is loaded on first use.
+func NewM3UClient(source string, opts ...M3UOption) (*M3UClient, error) {
+	if source == "" {
+		return nil, ErrInvalidBaseURL
+	}
+
+	client := &M3UClient{
+		source:     source,
+		httpClient: http.DefaultClient,
+		userAgent:  "go-iptv",
+	}
+
+	for _, opt := range opts {
+		opt(client)
+	}
+
+	return client, nil
+}
+
+// StreamService returns the client as a stream service
+func (c *M3UClient) StreamService() StreamService {
+	return c
+}
+
+// CategoryService returns the client as a category service
+func (c *M3UClient) CategoryService() CategoryService {
+	return c
+}
+
+// Reload downloads and parses the playlist again
+func (c *M3UClient) Reload(ctx context.Context) error {
+	playlist, err := c.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	streams := make([]Stream, 0, len(playlist.Entries))
+	urls := make(map[int]string, len(playlist.Entries))
+	seen := map[string]bool{}
+	var categories []Category
+
+	for _, entry := range playlist.Entries {
+		stream := entry.Stream()
+		stream.ID = m3uStreamID(entry)
+		for _, taken := urls[stream.ID]; taken; _, taken = urls[stream.ID] {
+			// Probe linearly so that duplicate entries and hash collisions
+			// keep distinct IDs, assigned in playlist order
+			stream.ID = (stream.ID + 1) & 0x7fffffff
+		}
+		stream.Type = m3uStreamType(entry)
+		stream.DirectSource = entry.URL
+
+		if stream.GroupTitle != "" {
+			stream.CategoryID = m3uCategoryID(stream.Type, stream.GroupTitle)
+			if !seen[stream.CategoryID] {
+				seen[stream.CategoryID] = true
+				categories = append(categories, Category{
+					ID:   stream.CategoryID,
+					Name: stream.GroupTitle,
+					Type: stream.Type,
+				})
+			}
+		}
+
+		streams = append(streams, stream)
+		urls[stream.ID] = entry.URL
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.streams = streams
+	c.categories = categories
+	c.urls = urls
+	c.loaded = true
+
+	return nil
+}
+
+func (c *M3UClient) fetch(ctx context.Context) (*Playlist, error) {
+	if !strings.HasPrefix(c.source, "http://") && !strings.HasPrefix(c.source, "https://") {
+		file, err := os.Open(strings.TrimPrefix(c.source, "file://"))
+		if err != nil {
+			return nil, fmt.Errorf("error opening playlist: %w", err)
+		}
+		defer file.Close()
+
+		return ParseM3U(file)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.source, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error performing request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return ParseM3U(resp.Body)
+}
+
+// snapshot returns the loaded streams and categories, loading the playlist
+// on first use
+func (c *M3UClient) snapshot(ctx context.Context) ([]Stream, []Category, error) {
+	c.mu.RLock()
+	loaded := c.loaded
+	c.mu.RUnlock()
+
+	if !loaded {
+		if err := c.Reload(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.streams, c.categories, nil
+}
+
+func (c *M3UClient) GetLive(ctx context.Context, opts ...RequestOption) ([]Stream, error) {
+	return c.getStreams(ctx, "live", opts)
+}
+
+func (c *M3UClient) GetVOD(ctx context.Context, opts ...RequestOption) ([]Stream, error) {
+	return c.getStreams(ctx, "movie", opts)
+}
+
+func (c *M3UClient) getStreams(ctx context.Context, kind string, opts []RequestOption) ([]Stream, error) {
+	options := &RequestOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	all, _, err := c.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	categoryIDs, err := resolveCategoryIDs(ctx, options, func(ctx context.Context, opts ...RequestOption) ([]Category, error) {
+		return c.getCategories(ctx, kind, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	selected := map[string]bool{}
+	for _, id := range categoryIDs {
+		selected[id] = true
+	}
+
+	streams := make([]Stream, 0)
+	for _, stream := range all {
+		if stream.Type != kind {
+			continue
+		}
+		if !selected[""] && !selected[stream.CategoryID] {
+			continue
+		}
+		streams = append(streams, stream)
+	}
+
+	return streamSchema.apply(streams, options)
+}
+
+// GetURL returns the URL of the playlist entry. The format is ignored because
+// playlist entries have a single fixed URL.
+func (c *M3UClient) GetURL(ctx context.Context, streamID int, format string) (string, error) {
+	if _, _, err := c.snapshot(ctx); err != nil {
+		return "", err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	streamURL, ok := c.urls[streamID]
+	if !ok {
+		return "", fmt.Errorf("%w: %d", ErrStreamNotFound, streamID)
+	}
+
+	return streamURL, nil
+}
+
+func (c *M3UClient) GetLiveCategories(ctx context.Context, opts ...RequestOption) ([]Category, error) {
+	return c.getCategories(ctx, "live", opts)
+}
+
+func (c *M3UClient) GetVODCategories(ctx context.Context, opts ...RequestOption) ([]Category, error) {
+	return c.getCategories(ctx, "movie", opts)
+}
+
+func (c *M3UClient) GetSeriesCategories(ctx context.Context, opts ...RequestOption) ([]Category, error) {
+	return c.getCategories(ctx, "series", opts)
+}
+
+func (c *M3UClient) getCategories(ctx context.Context, kind string, opts []RequestOption) ([]Category, error) {
+	options := &RequestOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	_, all, err := c.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	categories := make([]Category, 0)
+	for _, cat := range all {
+		if cat.Type == kind {
+			categories = append(categories, cat)
+		}
+	}
+
+	return categorySchema.apply(categories, options)
+}
+
+// m3uStreamType classifies a playlist entry as "live", "movie" or "series"
+func m3uStreamType(entry PlaylistEntry) string {
+	switch entry.StreamType {
+	case "movie", "series":
+		return entry.StreamType
+	}
+
+	if u, err := url.Parse(entry.URL); err == nil {
+		switch strings.ToLower(path.Ext(u.Path)) {
+		case ".mp4", ".mkv", ".avi", ".mov", ".m4v", ".wmv", ".mpg", ".mpeg":
+			return "movie"
+		}
+	}
+
+	return "live"
+}
+
+// m3uStreamID derives a stable positive ID from the entry's URL and name.
+// Reload resolves collisions by probing the following IDs.
+func m3uStreamID(entry PlaylistEntry) int {
+	streamURL := entry.URL
+	if u, err := url.Parse(entry.URL); err == nil {
+		u.RawQuery = ""
+		u.Fragment = ""
+		streamURL = u.String()
+	}
+
+	h := fnv.New32a()
+	io.WriteString(h, streamURL)
+	io.WriteString(h, "\x00")
+	io.WriteString(h, entry.Name)
+	return int(h.Sum32() & 0x7fffffff)
+}
+
+// m3uCategoryID derives a stable category ID from a group title
+func m3uCategoryID(kind, groupTitle string) string {
+	h := fnv.New32a()
+	io.WriteString(h, kind)
+	io.WriteString(h, "\x00")
+	io.WriteString(h, groupTitle)
+	return strconv.FormatUint(uint64(h.Sum32()), 10)
+}
@@ -0,0 +1,66 @@
+package iptv
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestM3UClientDuplicateEntries(t *testing.T) {
+	playlist := `#EXTM3U
+#EXTINF:-1 group-title="News",News One
+http://example.com/live/news.ts
+#EXTINF:-1 group-title="News",News One
+http://example.com/live/news.ts
+#EXTINF:-1 group-title="News",News One
+http://example.com/live/news.ts?token=abc
+`
+	file := filepath.Join(t.TempDir(), "playlist.m3u")
+	if err := os.WriteFile(file, []byte(playlist), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewM3UClient(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	streams, err := client.GetLive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 3 {
+		t.Fatalf("got %d streams, want 3", len(streams))
+	}
+
+	base := m3uStreamID(PlaylistEntry{URL: "http://example.com/live/news.ts", Name: "News One"})
+	for i, stream := range streams {
+		if want := base + i; stream.ID != want {
+			t.Errorf("stream %d: ID = %d, want %d", i, stream.ID, want)
+		}
+	}
+
+	url, err := client.GetURL(ctx, streams[2].ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "http://example.com/live/news.ts?token=abc" {
+		t.Errorf("GetURL = %q", url)
+	}
+
+	// Reloading assigns the same IDs again
+	if err := client.Reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	again, err := client.GetLive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range streams {
+		if again[i].ID != streams[i].ID {
+			t.Errorf("stream %d: ID changed from %d to %d after reload", i, streams[i].ID, again[i].ID)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package iptv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestURLBuilderStreamURL(t *testing.T) {
+	builder := &URLBuilder{BaseURL: "http://example.com/", Username: "user", Password: "pass"}
+
+	tests := []struct {
+		name    string
+		builder *URLBuilder
+		stream  Stream
+		format  string
+		want    string
+	}{
+		{
+			name:   "live default format",
+			stream: Stream{ID: 1, Type: "live"},
+			want:   "http://example.com/live/user/pass/1.ts",
+		},
+		{
+			name:   "live explicit format",
+			stream: Stream{ID: 1, Type: "live"},
+			format: "m3u8",
+			want:   "http://example.com/live/user/pass/1.m3u8",
+		},
+		{
+			name:   "movie container",
+			stream: Stream{ID: 2, Type: "movie", ContainerExt: "mkv"},
+			want:   "http://example.com/movie/user/pass/2.mkv",
+		},
+		{
+			name:   "vod type",
+			stream: Stream{ID: 3, Type: "vod", Container: "mp4"},
+			want:   "http://example.com/movie/user/pass/3.mp4",
+		},
+		{
+			name:   "series",
+			stream: Stream{ID: 4, Type: "series", Container: "mp4"},
+			want:   "http://example.com/series/user/pass/4.mp4",
+		},
+		{
+			name:    "proxy without credentials",
+			builder: &URLBuilder{BaseURL: "http://proxy.local"},
+			stream:  Stream{ID: 5, Type: "live"},
+			want:    "http://proxy.local/live/5.ts",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.builder
+			if b == nil {
+				b = builder
+			}
+			if got := b.StreamURL(tt.stream, tt.format); got != tt.want {
+				t.Errorf("StreamURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteM3U(t *testing.T) {
+	tests := []struct {
+		name    string
+		streams []Stream
+		cfg     M3UConfig
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "header and attributes",
+			streams: []Stream{{
+				ID:           1,
+				Name:         "News One",
+				Type:         "live",
+				Num:          7,
+				EPGChannelID: "news.one",
+				StreamIcon:   "http://example.com/logo.png",
+				CategoryID:   "3",
+			}},
+			cfg: M3UConfig{
+				EPGURL:        "http://example.com/xmltv.php",
+				URLBuilder:    &URLBuilder{BaseURL: "http://example.com", Username: "u", Password: "p"},
+				CategoryNames: map[string]string{"3": "News"},
+			},
+			want: `#EXTM3U x-tvg-url="http://example.com/xmltv.php"
+#EXTINF:-1 tvg-id="news.one" tvg-name="News One" tvg-logo="http://example.com/logo.png" tvg-chno="7" group-title="News",News One
+http://example.com/live/u/p/1.ts
+`,
+		},
+		{
+			name:    "escaping",
+			streams: []Stream{{ID: 2, Name: "Say \"Hi\"\nNow", Type: "live", GroupTitle: `A "B"`}},
+			cfg:     M3UConfig{ProxyBaseURL: "http://proxy.local", Format: "m3u8"},
+			want: `#EXTM3U
+#EXTINF:-1 tvg-name="Say 'Hi' Now" group-title="A 'B'",Say "Hi" Now
+http://proxy.local/live/2.m3u8
+`,
+		},
+		{
+			name:    "name template and catch-up",
+			streams: []Stream{{ID: 3, Name: "Sport", Type: "live", Num: 12, TVArchive: 1, TVArchiveDuration: 3}},
+			cfg: M3UConfig{
+				ProxyBaseURL: "http://proxy.local",
+				NameTemplate: "{{.Num}}. {{.Name}}",
+			},
+			want: `#EXTM3U
+#EXTINF:-1 tvg-name="Sport" tvg-chno="12" catchup="xc" catchup-days="3",12. Sport
+http://proxy.local/live/3.ts
+`,
+		},
+		{
+			name:    "missing URL builder",
+			cfg:     M3UConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "invalid template",
+			cfg:     M3UConfig{ProxyBaseURL: "http://proxy.local", NameTemplate: "{{"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf strings.Builder
+			err := WriteM3U(&buf, tt.streams, tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WriteM3U() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && buf.String() != tt.want {
+				t.Errorf("WriteM3U() =\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExtInf(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		duration float64
+		title    string
+		attrs    map[string]string
+	}{
+		{
+			name:     "attributes and title",
+			line:     `-1 tvg-id="news.one" group-title="News, World",News One`,
+			duration: -1,
+			title:    "News One",
+			attrs:    map[string]string{"tvg-id": "news.one", "group-title": "News, World"},
+		},
+		{
+			name:     "duration only",
+			line:     "120,Movie",
+			duration: 120,
+			title:    "Movie",
+			attrs:    map[string]string{},
+		},
+		{
+			name:     "leading space",
+			line:     ` -1 tvg-name="A",A`,
+			duration: -1,
+			title:    "A",
+			attrs:    map[string]string{"tvg-name": "A"},
+		},
+		{
+			name:     "missing duration",
+			line:     `tvg-id="x" group-title="G",Name`,
+			duration: -1,
+			title:    "Name",
+			attrs:    map[string]string{"tvg-id": "x", "group-title": "G"},
+		},
+		{
+			name:     "malformed duration",
+			line:     "abc,Name",
+			duration: -1,
+			title:    "Name",
+			attrs:    map[string]string{},
+		},
+		{
+			name:     "unquoted and mixed case keys",
+			line:     `-1 TVG-ID=abc tvg-chno=5,Five`,
+			duration: -1,
+			title:    "Five",
+			attrs:    map[string]string{"tvg-id": "abc", "tvg-chno": "5"},
+		},
+		{
+			name:     "comma in title",
+			line:     "-1,Hello, World",
+			duration: -1,
+			title:    "Hello, World",
+			attrs:    map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := parseExtInf(tt.line)
+			if entry.Duration != tt.duration {
+				t.Errorf("Duration = %v, want %v", entry.Duration, tt.duration)
+			}
+			if entry.Name != tt.title {
+				t.Errorf("Name = %q, want %q", entry.Name, tt.title)
+			}
+			if len(entry.Attributes) != len(tt.attrs) {
+				t.Errorf("Attributes = %v, want %v", entry.Attributes, tt.attrs)
+			}
+			for k, v := range tt.attrs {
+				if entry.Attributes[k] != v {
+					t.Errorf("Attributes[%q] = %q, want %q", k, entry.Attributes[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestParseM3U(t *testing.T) {
+	input := "\ufeff#EXTM3U x-tvg-url=\"http://example.com/epg.xml\"\n" +
+		"#EXTINF:-1 tvg-id=\"one\" tvg-chno=\"3\" catchup=\"default\" catchup-days=\"2\",Channel One\n" +
+		"#EXTVLCOPT:http-user-agent=Test\n" +
+		"http://example.com/live/user/pass/101.ts\n" +
+		"\n" +
+		"#EXTINF:-1,Film\n" +
+		"#EXTGRP:Movies\n" +
+		"http://example.com/movie/user/pass/202.mkv\n" +
+		"http://example.com/other/stream.m3u8\n"
+
+	playlist, err := ParseM3U(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := playlist.Attributes["x-tvg-url"]; got != "http://example.com/epg.xml" {
+		t.Errorf("x-tvg-url = %q", got)
+	}
+	if len(playlist.Entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(playlist.Entries))
+	}
+
+	tests := []struct {
+		name       string
+		title      string
+		streamType string
+		streamID   int
+		group      string
+	}{
+		{"live", "Channel One", "live", 101, ""},
+		{"movie", "Film", "movie", 202, "Movies"},
+		{"bare URL", "http://example.com/other/stream.m3u8", "", 0, ""},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := playlist.Entries[i]
+			if entry.Name != tt.title {
+				t.Errorf("Name = %q, want %q", entry.Name, tt.title)
+			}
+			if entry.StreamType != tt.streamType || entry.StreamID != tt.streamID {
+				t.Errorf("stream = %s/%d, want %s/%d", entry.StreamType, entry.StreamID, tt.streamType, tt.streamID)
+			}
+			if got := entry.Attributes["group-title"]; got != tt.group {
+				t.Errorf("group-title = %q, want %q", got, tt.group)
+			}
+		})
+	}
+
+	stream := playlist.Entries[0].Stream()
+	if stream.Num != 3 || stream.Catchup != "default" || stream.CatchupDays != 2 || stream.Container != "ts" {
+		t.Errorf("Stream() = %+v", stream)
+	}
+
+	if entry, ok := playlist.Lookup("vod", 202); !ok || entry.Name != "Film" {
+		t.Errorf("Lookup(vod, 202) = %+v, %v", entry, ok)
+	}
+}
+
+func TestPlaylistEnrich(t *testing.T) {
+	playlist := &Playlist{Entries: []PlaylistEntry{{
+		StreamID:   7,
+		StreamType: "live",
+		Attributes: map[string]string{"tvg-id": "seven", "group-title": "Group", "tvg-logo": "logo.png"},
+	}}}
+
+	streams := []Stream{
+		{ID: 7, Type: "live", TVGLogo: "own.png"},
+		{ID: 7, Type: "movie"},
+	}
+
+	enriched := playlist.Enrich(streams)
+	if enriched[0].TVGID != "seven" || enriched[0].GroupTitle != "Group" || enriched[0].TVGLogo != "own.png" {
+		t.Errorf("enriched live stream = %+v", enriched[0])
+	}
+	if enriched[1].TVGID != "" {
+		t.Errorf("movie with the same ID was enriched: %+v", enriched[1])
+	}
+	if streams[0].TVGID != "" {
+		t.Error("Enrich modified its input")
+	}
+}
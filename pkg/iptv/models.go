@@ -1,32 +1,83 @@
 package iptv
 
-import "time"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
-// Stream represents a media stream
+// Stream represents a media stream. The query tags define the keys used by
+// WithFilter and WithSort.
 type Stream struct {
-	ID           int    `json:"stream_id"`
-	Name         string `json:"name"`
-	Type         string `json:"stream_type"`
-	StreamType   string `json:"type"`
-	CategoryID   string `json:"category_id"`
-	AVCLevel     string `json:"avc_level,omitempty"`
-	Container    string `json:"container,omitempty"`
-	CustomSID    string `json:"custom_sid,omitempty"`
-	DirectSource string `json:"direct_source,omitempty"`
+	ID           int    `json:"stream_id" query:"stream_id,id"`
+	Name         string `json:"name" query:"name"`
+	Type         string `json:"stream_type" query:"stream_type,type"`
+	StreamType   string `json:"type" query:"kind"`
+	CategoryID   string `json:"category_id" query:"category_id"`
+	AVCLevel     string `json:"avc_level,omitempty" query:"avc_level"`
+	Container    string `json:"container,omitempty" query:"container"`
+	CustomSID    string `json:"custom_sid,omitempty" query:"custom_sid"`
+	DirectSource string `json:"direct_source,omitempty" query:"direct_source"`
+
+	// Xtream listing fields
+	Num               FlexInt `json:"num,omitempty" query:"num,tvg-chno"`
+	StreamIcon        string  `json:"stream_icon,omitempty" query:"stream_icon"`
+	ContainerExt      string  `json:"container_extension,omitempty" query:"container_extension"`
+	EPGChannelID      string  `json:"epg_channel_id,omitempty" query:"epg_channel_id"`
+	TVArchive         FlexInt `json:"tv_archive,omitempty" query:"tv_archive"`
+	TVArchiveDuration FlexInt `json:"tv_archive_duration,omitempty" query:"tv_archive_duration"`
+	IsAdult           FlexInt `json:"is_adult,omitempty" query:"is_adult"`
 
 	// M3U specific fields
-	TVGID      string `json:"tvg_id,omitempty"`
-	TVGName    string `json:"tvg_name,omitempty"`
-	TVGLogo    string `json:"tvg_logo,omitempty"`
-	GroupTitle string `json:"group_title,omitempty"`
+	TVGID      string `json:"tvg_id,omitempty" query:"tvg-id"`
+	TVGName    string `json:"tvg_name,omitempty" query:"tvg-name"`
+	TVGLogo    string `json:"tvg_logo,omitempty" query:"tvg-logo"`
+	GroupTitle string `json:"group_title,omitempty" query:"group-title"`
+
+	// M3U catch-up attributes
+	Catchup       string  `json:"catchup,omitempty" query:"catchup"`
+	CatchupSource string  `json:"catchup_source,omitempty" query:"catchup-source"`
+	CatchupDays   int     `json:"catchup_days,omitempty" query:"catchup-days"`
+	TVGShift      float64 `json:"tvg_shift,omitempty" query:"tvg-shift"`
 }
 
 // Category represents a content category
 type Category struct {
-	ID       string `json:"category_id"`
-	Name     string `json:"category_name"`
-	ParentID int    `json:"parent_id"`
-	Type     string `json:"type"`
+	ID       string `json:"category_id" query:"category_id,id"`
+	Name     string `json:"category_name" query:"category_name,name"`
+	ParentID int    `json:"parent_id" query:"parent_id"`
+	Type     string `json:"type" query:"type"`
+}
+
+// Series represents a TV series
+type Series struct {
+	ID             int     `json:"series_id" query:"series_id,id"`
+	Num            FlexInt `json:"num,omitempty" query:"num"`
+	Name           string  `json:"name" query:"name"`
+	CategoryID     string  `json:"category_id" query:"category_id"`
+	Cover          string  `json:"cover,omitempty" query:"cover"`
+	Plot           string  `json:"plot,omitempty" query:"plot"`
+	Cast           string  `json:"cast,omitempty" query:"cast"`
+	Director       string  `json:"director,omitempty" query:"director"`
+	Genre          string  `json:"genre,omitempty" query:"genre"`
+	ReleaseDate    string  `json:"releaseDate,omitempty" query:"release_date"`
+	LastModified   string  `json:"last_modified,omitempty" query:"last_modified"`
+	YoutubeTrailer string  `json:"youtube_trailer,omitempty" query:"youtube_trailer"`
+	IsAdult        FlexInt `json:"is_adult,omitempty" query:"is_adult"`
+}
+
+// Episode represents a series episode as returned by get_series_info
+type Episode struct {
+	ID           FlexInt `json:"id"`
+	SeriesID     int     `json:"series_id"`
+	Season       FlexInt `json:"season"`
+	EpisodeNum   FlexInt `json:"episode_num"`
+	Title        string  `json:"title"`
+	ContainerExt string  `json:"container_extension,omitempty"`
+	Added        string  `json:"added,omitempty"`
+	CustomSID    string  `json:"custom_sid,omitempty"`
+	DirectSource string  `json:"direct_source,omitempty"`
 }
 
 // EPGInfo represents an EPG entry
@@ -47,3 +98,51 @@ type EPGInfo struct {
 type EPGContainer struct {
 	EPGListings []EPGInfo `json:"epg_listings"`
 }
+
+// AccountInfo is the response of player_api.php without an action
+type AccountInfo struct {
+	UserInfo   UserInfo   `json:"user_info"`
+	ServerInfo ServerInfo `json:"server_info"`
+}
+
+// UserInfo describes an Xtream account and its connection usage
+type UserInfo struct {
+	Username             string   `json:"username"`
+	Status               string   `json:"status"`
+	Auth                 FlexInt  `json:"auth"`
+	ExpDate              FlexInt  `json:"exp_date"`
+	IsTrial              FlexInt  `json:"is_trial"`
+	ActiveConnections    FlexInt  `json:"active_cons"`
+	MaxConnections       FlexInt  `json:"max_connections"`
+	CreatedAt            FlexInt  `json:"created_at"`
+	AllowedOutputFormats []string `json:"allowed_output_formats"`
+}
+
+// ServerInfo describes the panel serving an account
+type ServerInfo struct {
+	URL            string  `json:"url"`
+	Port           FlexInt `json:"port"`
+	HTTPSPort      FlexInt `json:"https_port"`
+	ServerProtocol string  `json:"server_protocol"`
+	Timezone       string  `json:"timezone"`
+	TimestampNow   FlexInt `json:"timestamp_now"`
+}
+
+// FlexInt is an integer that panels may encode either as a JSON number or as a string
+type FlexInt int
+
+// UnmarshalJSON accepts numbers, numeric strings, empty strings and null
+func (f *FlexInt) UnmarshalJSON(data []byte) error {
+	raw := strings.Trim(strings.TrimSpace(string(data)), `"`)
+	if raw == "" || raw == "null" {
+		*f = 0
+		return nil
+	}
+
+	n, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return fmt.Errorf("invalid integer value %s", data)
+	}
+	*f = FlexInt(n)
+	return nil
+}
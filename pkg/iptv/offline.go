@@ -0,0 +1,355 @@
+package iptv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// SnapshotService answers stream, category and EPG calls from a saved
+// catalog, with the same filtering, sorting and pagination as the client.
+// EPG queries need a catalog taken WithGuide.
+type SnapshotService struct {
+	catalog *Catalog
+	urls    *URLBuilder
+}
+
+var (
+	_ StreamService   = (*SnapshotService)(nil)
+	_ CategoryService = (*SnapshotService)(nil)
+	_ EPGService      = (*SnapshotService)(nil)
+)
+
+// NewSnapshotService serves a catalog. urls builds the stream URLs returned
+// by GetURL and may be nil when URLs are not needed.
+func NewSnapshotService(catalog *Catalog, urls *URLBuilder) *SnapshotService {
+	return &SnapshotService{catalog: catalog, urls: urls}
+}
+
+// StreamService returns the snapshot as a StreamService
+func (s *SnapshotService) StreamService() StreamService {
+	return s
+}
+
+// CategoryService returns the snapshot as a CategoryService
+func (s *SnapshotService) CategoryService() CategoryService {
+	return s
+}
+
+// EPGService returns the snapshot as an EPGService
+func (s *SnapshotService) EPGService() EPGService {
+	return s
+}
+
+func (s *SnapshotService) GetLive(ctx context.Context, opts ...RequestOption) ([]Stream, error) {
+	return s.getStreams(ctx, s.catalog.Live, s.GetLiveCategories, opts)
+}
+
+func (s *SnapshotService) GetVOD(ctx context.Context, opts ...RequestOption) ([]Stream, error) {
+	return s.getStreams(ctx, s.catalog.VOD, s.GetVODCategories, opts)
+}
+
+func (s *SnapshotService) getStreams(ctx context.Context, all []Stream, categories func(context.Context, ...RequestOption) ([]Category, error), opts []RequestOption) ([]Stream, error) {
+	options := &RequestOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	categoryIDs, err := resolveCategoryIDs(ctx, options, categories)
+	if err != nil {
+		return nil, err
+	}
+
+	streams := make([]Stream, 0)
+	for _, id := range categoryIDs {
+		streams = append(streams, streamsInCategory(all, id)...)
+	}
+	if len(categoryIDs) > 1 {
+		streams = uniqueBy(streams, streamKey)
+	}
+
+	return streamSchema.apply(streams, options)
+}
+
+// GetURL builds the URL of a stream of the catalog
+func (s *SnapshotService) GetURL(ctx context.Context, streamID int, format string) (string, error) {
+	stream, ok := s.stream(streamID)
+	if !ok {
+		return "", fmt.Errorf("%w: %d", ErrStreamNotFound, streamID)
+	}
+	if s.urls == nil {
+		return "", ErrMissingURLBuilder
+	}
+
+	return s.urls.StreamURL(stream, format), nil
+}
+
+func (s *SnapshotService) GetLiveCategories(ctx context.Context, opts ...RequestOption) ([]Category, error) {
+	return s.getCategories(s.catalog.LiveCategories, opts)
+}
+
+func (s *SnapshotService) GetVODCategories(ctx context.Context, opts ...RequestOption) ([]Category, error) {
+	return s.getCategories(s.catalog.VODCategories, opts)
+}
+
+func (s *SnapshotService) GetSeriesCategories(ctx context.Context, opts ...RequestOption) ([]Category, error) {
+	return s.getCategories(s.catalog.SeriesCategories, opts)
+}
+
+func (s *SnapshotService) getCategories(all []Category, opts []RequestOption) ([]Category, error) {
+	options := &RequestOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	// Never hand out the catalog's own slice
+	categories := append(make([]Category, 0, len(all)), all...)
+	return categorySchema.apply(categories, options)
+}
+
+// GetShortEPG returns the current and upcoming programmes of a stream
+func (s *SnapshotService) GetShortEPG(ctx context.Context, streamID string, limit int) ([]EPGInfo, error) {
+	programmes, err := s.programmes(streamID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	upcoming := make([]EPGInfo, 0)
+	for _, programme := range programmes {
+		if _, end := programmeWindow(programme); end.After(now) {
+			upcoming = append(upcoming, programme)
+		}
+	}
+	if limit > 0 && len(upcoming) > limit {
+		upcoming = upcoming[:limit]
+	}
+
+	return upcoming, nil
+}
+
+// GetFullEPG returns every programme of a stream in the guide
+func (s *SnapshotService) GetFullEPG(ctx context.Context, streamID string) ([]EPGInfo, error) {
+	programmes, err := s.programmes(streamID)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(programmes), nil
+}
+
+// GetXMLTV renders the guide of the catalog as XMLTV
+func (s *SnapshotService) GetXMLTV(ctx context.Context) ([]byte, error) {
+	if s.catalog.Guide == nil {
+		return nil, fmt.Errorf("%w: guide", ErrNotInSnapshot)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteXMLTV(&buf, s.catalog.Guide); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// programmes returns the guide entries of a live stream
+func (s *SnapshotService) programmes(streamID string) ([]EPGInfo, error) {
+	if s.catalog.Guide == nil {
+		return nil, fmt.Errorf("%w: guide", ErrNotInSnapshot)
+	}
+
+	id, err := strconv.Atoi(streamID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrStreamNotFound, streamID)
+	}
+	stream, ok := s.stream(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrStreamNotFound, streamID)
+	}
+
+	return s.catalog.Guide[firstNonEmpty(stream.EPGChannelID, stream.TVGID)], nil
+}
+
+// stream looks up a live or VOD stream by ID
+func (s *SnapshotService) stream(id int) (Stream, bool) {
+	for _, list := range [][]Stream{s.catalog.Live, s.catalog.VOD} {
+		for _, stream := range list {
+			if stream.ID == id {
+				return stream, true
+			}
+		}
+	}
+	return Stream{}, false
+}
+
+// answer returns the catalog data for a player_api.php request, in the shape
+// the panel would have returned it
+func (s *SnapshotService) answer(params map[string]string) (any, bool) {
+	categoryID := params["category_id"]
+
+	switch params["action"] {
+	case "get_live_categories":
+		return s.catalog.LiveCategories, s.catalog.LiveCategories != nil
+	case "get_vod_categories":
+		return s.catalog.VODCategories, s.catalog.VODCategories != nil
+	case "get_series_categories":
+		return s.catalog.SeriesCategories, s.catalog.SeriesCategories != nil
+	case "get_live_streams":
+		return streamsInCategory(s.catalog.Live, categoryID), s.catalog.Live != nil
+	case "get_vod_streams":
+		return streamsInCategory(s.catalog.VOD, categoryID), s.catalog.VOD != nil
+	case "get_series":
+		series := make([]Series, 0)
+		for _, item := range s.catalog.Series {
+			if categoryID == "" || item.CategoryID == categoryID {
+				series = append(series, item)
+			}
+		}
+		return series, s.catalog.Series != nil
+	case "get_series_info":
+		id, _ := strconv.Atoi(params["series_id"])
+		episodes, ok := s.catalog.Episodes[id]
+		seasons := map[string][]Episode{}
+		for _, episode := range episodes {
+			season := strconv.Itoa(int(episode.Season))
+			seasons[season] = append(seasons[season], episode)
+		}
+		return map[string]any{"episodes": seasons}, ok
+	case "get_stream_info":
+		id, _ := strconv.Atoi(params["stream_id"])
+		return s.stream(id)
+	case "get_short_epg":
+		limit, _ := strconv.Atoi(params["limit"])
+		programmes, err := s.GetShortEPG(context.Background(), params["stream_id"], limit)
+		return EPGContainer{EPGListings: programmes}, err == nil
+	case "get_simple_data_table":
+		programmes, err := s.GetFullEPG(context.Background(), params["stream_id"])
+		return EPGContainer{EPGListings: programmes}, err == nil
+	}
+	return nil, false
+}
+
+// streamsInCategory returns the streams of a category, or all streams for
+// an empty category ID
+func streamsInCategory(all []Stream, categoryID string) []Stream {
+	streams := make([]Stream, 0)
+	for _, stream := range all {
+		if categoryID == "" || stream.CategoryID == categoryID {
+			streams = append(streams, stream)
+		}
+	}
+	return streams
+}
+
+// uniqueBy drops items whose key was already seen
+func uniqueBy[T any, K comparable](items []T, key func(*T) K) []T {
+	unique := items[:0:0]
+	seen := map[K]bool{}
+	for i := range items {
+		if k := key(&items[i]); !seen[k] {
+			seen[k] = true
+			unique = append(unique, items[i])
+		}
+	}
+	return unique
+}
+
+// WithSnapshotFallback answers API calls from a catalog when the provider
+// cannot be reached or fails with a server error. Results served from the
+// catalog are marked stale, see WithStale.
+func WithSnapshotFallback(catalog *Catalog) Option {
+	return WithSnapshotFallbackFunc(func() *Catalog { return catalog })
+}
+
+// WithSnapshotFallbackFunc is WithSnapshotFallback with a catalog that can
+// change over time, e.g. CatalogWatcher.Catalog. fn may return nil when no
+// catalog is available yet.
+func WithSnapshotFallbackFunc(fn func() *Catalog) Option {
+	return func(c *Client) error {
+		c.fallback = fn
+		return nil
+	}
+}
+
+// WithStale reports whether any part of the result was served from the
+// fallback snapshot instead of the provider
+func WithStale(stale *bool) RequestOption {
+	return func(opts *RequestOptions) {
+		opts.stale = stale
+	}
+}
+
+type staleKey struct{}
+
+// trackStale returns a context that records fallback answers and a function
+// storing the outcome in stale
+func trackStale(ctx context.Context, stale *bool) (context.Context, func()) {
+	if stale == nil {
+		return ctx, func() {}
+	}
+
+	*stale = false
+	flag := new(atomic.Bool)
+	return context.WithValue(ctx, staleKey{}, flag), func() { *stale = flag.Load() }
+}
+
+// markStale records that a request of the context was answered from the
+// fallback snapshot
+func markStale(ctx context.Context) {
+	if flag, ok := ctx.Value(staleKey{}).(*atomic.Bool); ok {
+		flag.Store(true)
+	}
+}
+
+type noFallbackKey struct{}
+
+// withoutFallback returns a context whose requests fail rather than being
+// answered from the fallback snapshot. Snapshot and CatalogWatcher use it so
+// that a fresh catalog is never filled with data of the old one.
+func withoutFallback(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noFallbackKey{}, true)
+}
+
+// fallbackDisabled reports whether the request context opted out of the
+// fallback snapshot
+func fallbackDisabled(ctx context.Context) bool {
+	disabled, _ := ctx.Value(noFallbackKey{}).(bool)
+	return disabled
+}
+
+// fallbackCatalog returns the catalog to answer a failed request from, or
+// nil when there is no fallback, the request opted out of it or the failure
+// was not caused by the provider
+func (c *Client) fallbackCatalog(ctx context.Context, err error) *Catalog {
+	if c.fallback == nil || fallbackDisabled(ctx) || !isUnavailable(ctx, err) {
+		return nil
+	}
+	return c.fallback()
+}
+
+// answerFromSnapshot decodes the fallback catalog's answer to a failed API
+// request into v. It reports false when fallbackCatalog has no catalog or
+// the catalog cannot answer the request.
+func (c *Client) answerFromSnapshot(ctx context.Context, params map[string]string, v interface{}, err error) bool {
+	catalog := c.fallbackCatalog(ctx, err)
+	if catalog == nil {
+		return false
+	}
+
+	data, ok := NewSnapshotService(catalog, nil).answer(params)
+	if !ok {
+		return false
+	}
+
+	// Round trip through JSON so that v can be any type the API decodes into
+	raw, err := json.Marshal(data)
+	if err != nil || json.Unmarshal(raw, v) != nil {
+		return false
+	}
+
+	markStale(ctx)
+	return true
+}
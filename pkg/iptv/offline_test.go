@@ -0,0 +1,110 @@
+package iptv
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotFallback(t *testing.T) {
+	panel := newTestPanel(t, map[string]any{
+		"get_live_categories":   []Category{{ID: "1", Name: "News"}},
+		"get_vod_categories":    []Category{},
+		"get_vod_streams":       []Stream{},
+		"get_series_categories": []Category{},
+		"get_series":            []Series{},
+	})
+	panel.handle("get_live_streams", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	})
+
+	saved := &Catalog{
+		LiveCategories: []Category{{ID: "1", Name: "News"}},
+		Live:           []Stream{{ID: 7, Name: "Saved News", CategoryID: "1"}},
+
+		// Complete, so that only the opt-out keeps Snapshot from using it
+		VODCategories:    []Category{},
+		VOD:              []Stream{},
+		SeriesCategories: []Category{},
+		Series:           []Series{},
+	}
+	client := newTestClient(t, panel.URL, WithSnapshotFallback(saved))
+	ctx := context.Background()
+
+	var stale bool
+	streams, err := client.StreamService().GetLive(ctx, WithStale(&stale))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stale || len(streams) != 1 || streams[0].ID != 7 {
+		t.Errorf("GetLive() = %+v, stale %v", streams, stale)
+	}
+
+	categories, err := client.CategoryService().GetLiveCategories(ctx, WithStale(&stale))
+	if err != nil || stale || len(categories) != 1 {
+		t.Errorf("GetLiveCategories() = %+v, %v, stale %v", categories, err, stale)
+	}
+
+	if _, err := client.Snapshot(ctx); err == nil {
+		t.Error("Snapshot() answered from the fallback catalog")
+	}
+
+	watcher := NewCatalogWatcher(client.StreamService(), client.CategoryService())
+	if err := watcher.refresh(ctx, watcher.fetchStreams, false); err == nil {
+		t.Error("watcher refresh answered from the fallback catalog")
+	}
+}
+
+func TestEPGFallbackStale(t *testing.T) {
+	panel := newTestPanel(t, nil)
+	panel.handle("xmltv.php", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	})
+
+	start := time.Date(2025, 6, 1, 20, 0, 0, 0, time.UTC)
+	saved := &Catalog{
+		Live: []Stream{{ID: 7, Name: "News", EPGChannelID: "news.one"}},
+		Guide: map[string][]EPGInfo{
+			"news.one": {{Title: "Evening News", Start: start, End: start.Add(time.Hour)}},
+		},
+	}
+	client := newTestClient(t, panel.URL, WithSnapshotFallback(saved))
+
+	epg, ok := client.EPGService().(EPGRequestService)
+	if !ok {
+		t.Fatal("the client's EPG service does not implement EPGRequestService")
+	}
+
+	var stale bool
+	data, err := epg.GetXMLTVWithOptions(context.Background(), WithStale(&stale))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stale || !strings.Contains(string(data), "Evening News") {
+		t.Errorf("GetXMLTVWithOptions() = %q, stale %v", data, stale)
+	}
+
+	if _, err := client.EPGService().GetXMLTV(context.Background()); err != nil {
+		t.Errorf("GetXMLTV() = %v", err)
+	}
+}
+
+// legacyEPG implements EPGService the way code written before request
+// options did
+type legacyEPG struct{}
+
+func (legacyEPG) GetShortEPG(ctx context.Context, streamID string, limit int) ([]EPGInfo, error) {
+	return nil, nil
+}
+
+func (legacyEPG) GetFullEPG(ctx context.Context, streamID string) ([]EPGInfo, error) {
+	return nil, nil
+}
+
+func (legacyEPG) GetXMLTV(ctx context.Context) ([]byte, error) {
+	return nil, nil
+}
+
+var _ EPGService = legacyEPG{}
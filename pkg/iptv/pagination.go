@@ -0,0 +1,82 @@
+package iptv
+
+import (
+	"context"
+	"iter"
+)
+
+// Page is a single page of results
+type Page[T any] struct {
+	Items []T
+	// Number is the zero-based page number
+	Number int
+	// Offset is the position of the first item in the full result set
+	Offset int
+	// Total is the size of the full result set
+	Total int
+}
+
+// Last reports whether this is the last page
+func (p Page[T]) Last() bool {
+	return p.Offset+len(p.Items) >= p.Total
+}
+
+// Pages fetches the results of a service call once and yields them in pages of
+// pageSize items. Options are passed to fetch; offset and limit are managed
+// by Pages and must not be set.
+//
+//	for page, err := range iptv.Pages(ctx, client.StreamService().GetVOD, 100) {
+//		...
+//	}
+func Pages[T any](ctx context.Context, fetch func(context.Context, ...RequestOption) ([]T, error), pageSize int, opts ...RequestOption) iter.Seq2[Page[T], error] {
+	return func(yield func(Page[T], error) bool) {
+		if pageSize <= 0 {
+			pageSize = 100
+		}
+
+		opts = append(opts[:len(opts):len(opts)], WithOffset(0), WithLimit(0))
+		items, err := fetch(ctx, opts...)
+		if err != nil {
+			yield(Page[T]{}, err)
+			return
+		}
+
+		for number, offset := 0, 0; offset < len(items) || number == 0; number, offset = number+1, offset+pageSize {
+			if err := ctx.Err(); err != nil {
+				yield(Page[T]{}, err)
+				return
+			}
+
+			end := min(offset+pageSize, len(items))
+			page := Page[T]{
+				Items:  items[offset:end],
+				Number: number,
+				Offset: offset,
+				Total:  len(items),
+			}
+			if !yield(page, nil) {
+				return
+			}
+		}
+	}
+}
+
+// paginate applies the offset and limit of the options and reports the total
+func paginate[T any](items []T, options *RequestOptions) []T {
+	if options.total != nil {
+		*options.total = len(items)
+	}
+
+	if options.Offset > 0 {
+		if options.Offset >= len(items) {
+			return items[:0]
+		}
+		items = items[options.Offset:]
+	}
+
+	if options.Limit > 0 && options.Limit < len(items) {
+		items = items[:options.Limit]
+	}
+
+	return items
+}
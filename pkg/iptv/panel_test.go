@@ -0,0 +1,105 @@
+package iptv
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync"
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+// testPanel is a fake Xtream panel. Requests are answered by route, which is
+// the action of player_api.php requests and the file name otherwise, e.g.
+// "xmltv.php". A route is answered with its handler, or with its response
+// encoded as JSON.
+type testPanel struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	hits      map[string]int
+	responses map[string]any
+	handlers  map[string]http.HandlerFunc
+}
+
+func newTestPanel(t *testing.T, responses map[string]any) *testPanel {
+	t.Helper()
+
+	p := &testPanel{
+		hits:      map[string]int{},
+		responses: responses,
+		handlers:  map[string]http.HandlerFunc{},
+	}
+	p.Server = httptest.NewServer(http.HandlerFunc(p.serve))
+	t.Cleanup(p.Close)
+	return p
+}
+
+func panelRoute(r *http.Request) string {
+	if action := r.URL.Query().Get("action"); action != "" {
+		return action
+	}
+	return path.Base(r.URL.Path)
+}
+
+func (p *testPanel) serve(w http.ResponseWriter, r *http.Request) {
+	route := panelRoute(r)
+
+	p.mu.Lock()
+	p.hits[route]++
+	handler, response := p.handlers[route], p.responses[route]
+	p.mu.Unlock()
+
+	if handler != nil {
+		handler(w, r)
+		return
+	}
+	if response == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handle answers a route with a handler instead of the canned response
+func (p *testPanel) handle(route string, handler http.HandlerFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.handlers[route] = handler
+}
+
+// count returns how many requests a route received
+func (p *testPanel) count(route string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.hits[route]
+}
+
+// newTestClient creates a client without rate limit for the panels
+func newTestClient(t *testing.T, baseURL string, opts ...Option) *Client {
+	t.Helper()
+	return newTestClientWithConfig(t, &Config{BaseURL: baseURL}, opts...)
+}
+
+func newTestClientWithConfig(t *testing.T, cfg *Config, opts ...Option) *Client {
+	t.Helper()
+
+	cfg.Username, cfg.Password = "user", "pass"
+	if cfg.RateLimit == 0 {
+		cfg.RateLimit = rate.Inf
+	}
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = "go-iptv-test"
+	}
+
+	client, err := NewClient(cfg, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
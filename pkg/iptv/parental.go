@@ -0,0 +1,192 @@
+package iptv
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"regexp"
+)
+
+// DefaultAdultPatterns match the names of adult categories and streams such
+// as "XXX", "18+" or "Adults Only". "Adult" on its own only matches as the
+// whole name, optionally after a country prefix, so that "Adult Swim" stays
+// visible.
+var DefaultAdultPatterns = []string{
+	`(?i)(^|[^\pL\pN])(xxx|18\+|porn\w*|erotic\w*|adults?\s+only)($|[^\pL\pN])`,
+	`(?i)^[^\pL\pN]*(\pL{2,3}\s*[|:•\])-]+\s*)?adults?[^\pL\pN]*$`,
+}
+
+// ParentalControl flags adult content by the Xtream is_adult field and by
+// name patterns. Adult content is hidden unless a request carries the PIN.
+type ParentalControl struct {
+	patterns []*regexp.Regexp
+	pin      [sha256.Size]byte
+}
+
+// NewParentalControl creates a parental control unlocked by pin. Names are
+// matched against patterns, DefaultAdultPatterns when none are given.
+func NewParentalControl(pin string, patterns ...string) (*ParentalControl, error) {
+	if len(patterns) == 0 {
+		patterns = DefaultAdultPatterns
+	}
+
+	p := &ParentalControl{pin: sha256.Sum256([]byte(pin))}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid adult pattern: %w", err)
+		}
+		p.patterns = append(p.patterns, re)
+	}
+	return p, nil
+}
+
+// WithParentalControl hides adult streams and categories from GetLive,
+// GetVOD and the category methods. Requests made with WithPIN(pin) see
+// everything.
+func WithParentalControl(pin string, patterns ...string) Option {
+	return func(c *Client) error {
+		p, err := NewParentalControl(pin, patterns...)
+		if err != nil {
+			return err
+		}
+		c.parental = p
+		return nil
+	}
+}
+
+// WithPIN unlocks adult content for a request. A wrong PIN fails the request
+// with ErrInvalidPIN.
+func WithPIN(pin string) RequestOption {
+	return func(opts *RequestOptions) {
+		opts.PIN = pin
+	}
+}
+
+// unlockParental lets the client look up adult categories internally
+func unlockParental() RequestOption {
+	return func(opts *RequestOptions) {
+		opts.unlocked = true
+	}
+}
+
+// IsAdultName reports whether a name matches one of the adult patterns
+func (p *ParentalControl) IsAdultName(name string) bool {
+	for _, re := range p.patterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdultCategory reports whether a category is adult by its name. Use
+// AdultCategoryIDs to also catch the subcategories of adult categories.
+func (p *ParentalControl) IsAdultCategory(category Category) bool {
+	return p.IsAdultName(category.Name)
+}
+
+// AdultCategoryIDs returns the IDs of the adult categories in a list along
+// with the IDs of all their descendants, following the parent links of
+// CategoryTree
+func (p *ParentalControl) AdultCategoryIDs(categories []Category) map[string]bool {
+	adult := map[string]bool{}
+	NewCategoryTree(categories).Walk(func(node *CategoryNode, depth int) bool {
+		if !p.IsAdultCategory(node.Category) {
+			return true
+		}
+		adult[node.ID] = true
+		for _, child := range node.Descendants() {
+			adult[child.ID] = true
+		}
+		return false
+	})
+	return adult
+}
+
+// IsAdultStream reports whether a stream is adult by its is_adult field or
+// its name
+func (p *ParentalControl) IsAdultStream(stream Stream) bool {
+	return stream.IsAdult != 0 || p.IsAdultName(stream.Name)
+}
+
+// IsAdultSeries reports whether a series is adult by its is_adult field or
+// its name
+func (p *ParentalControl) IsAdultSeries(series Series) bool {
+	return series.IsAdult != 0 || p.IsAdultName(series.Name)
+}
+
+// locked reports whether adult content has to be hidden from a request. A
+// nil parental control never locks anything.
+func (p *ParentalControl) locked(options *RequestOptions) (bool, error) {
+	if p == nil || options.unlocked {
+		return false, nil
+	}
+	if options.PIN == "" {
+		return true, nil
+	}
+
+	sum := sha256.Sum256([]byte(options.PIN))
+	if subtle.ConstantTimeCompare(sum[:], p.pin[:]) != 1 {
+		return false, ErrInvalidPIN
+	}
+	return false, nil
+}
+
+// filterCategories drops adult categories and their subcategories unless
+// the request is unlocked
+func (p *ParentalControl) filterCategories(categories []Category, options *RequestOptions) ([]Category, error) {
+	locked, err := p.locked(options)
+	if err != nil || !locked {
+		return categories, err
+	}
+
+	adult := p.AdultCategoryIDs(categories)
+	allowed := make([]Category, 0, len(categories))
+	for _, category := range categories {
+		if !adult[category.ID] {
+			allowed = append(allowed, category)
+		}
+	}
+	return allowed, nil
+}
+
+// filterStreams drops adult streams and the streams of adult categories
+// unless the request is unlocked. Categories are only fetched when needed.
+func (p *ParentalControl) filterStreams(ctx context.Context, streams []Stream, options *RequestOptions, list func(context.Context, ...RequestOption) ([]Category, error)) ([]Stream, error) {
+	return filterAdult(ctx, p, streams, options, list, func(stream Stream) (string, bool) {
+		return stream.CategoryID, p.IsAdultStream(stream)
+	})
+}
+
+// filterSeries is filterStreams for series
+func (p *ParentalControl) filterSeries(ctx context.Context, series []Series, options *RequestOptions, list func(context.Context, ...RequestOption) ([]Category, error)) ([]Series, error) {
+	return filterAdult(ctx, p, series, options, list, func(series Series) (string, bool) {
+		return series.CategoryID, p.IsAdultSeries(series)
+	})
+}
+
+// filterAdult drops adult items and the items of adult categories and their
+// subcategories unless the request is unlocked. classify returns the category ID of an item and
+// whether the item itself is adult.
+func filterAdult[T any](ctx context.Context, p *ParentalControl, items []T, options *RequestOptions, list func(context.Context, ...RequestOption) ([]Category, error), classify func(T) (string, bool)) ([]T, error) {
+	locked, err := p.locked(options)
+	if err != nil || !locked {
+		return items, err
+	}
+
+	categories, err := list(ctx, unlockParental())
+	if err != nil {
+		return nil, err
+	}
+	adult := p.AdultCategoryIDs(categories)
+
+	allowed := make([]T, 0, len(items))
+	for _, item := range items {
+		if categoryID, isAdult := classify(item); !adult[categoryID] && !isAdult {
+			allowed = append(allowed, item)
+		}
+	}
+	return allowed, nil
+}
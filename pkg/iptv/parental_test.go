@@ -0,0 +1,102 @@
+package iptv
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestIsAdultName(t *testing.T) {
+	p, err := NewParentalControl("1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"XXX", true},
+		{"UK | XXX Movies", true},
+		{"Adult", true},
+		{"ADULTS", true},
+		{"|EN| Adult", true},
+		{"FR: Adultes", false},
+		{"Adults Only", true},
+		{"Movies 18+", true},
+		{"Erotica", true},
+		{"Adult Swim", false},
+		{"US | Adult Swim HD", false},
+		{"Young Adult Fiction", false},
+		{"Sports", false},
+		{"XXXL Channel", false},
+		{"Kids 8+", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.IsAdultName(tt.name); got != tt.want {
+				t.Errorf("IsAdultName(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParentalControlSubcategories(t *testing.T) {
+	p, err := NewParentalControl("1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	categories := []Category{
+		{ID: "1", Name: "Movies"},
+		{ID: "2", Name: "XXX"},
+		{ID: "3", Name: "Amateur", ParentID: 2},
+		{ID: "4", Name: "Classics", ParentID: 3},
+		{ID: "5", Name: "Comedy", ParentID: 1},
+	}
+
+	adult := p.AdultCategoryIDs(categories)
+	for _, id := range []string{"2", "3", "4"} {
+		if !adult[id] {
+			t.Errorf("category %s is not adult", id)
+		}
+	}
+	for _, id := range []string{"1", "5"} {
+		if adult[id] {
+			t.Errorf("category %s is adult", id)
+		}
+	}
+
+	allowed, err := p.filterCategories(categories, &RequestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(allowed) != 2 || allowed[0].ID != "1" || allowed[1].ID != "5" {
+		t.Errorf("filterCategories() = %+v", allowed)
+	}
+
+	streams := []Stream{
+		{ID: 1, Name: "Film", CategoryID: "5"},
+		{ID: 2, Name: "Film", CategoryID: "4"},
+		{ID: 3, Name: "Film", CategoryID: "1", IsAdult: 1},
+	}
+	list := func(context.Context, ...RequestOption) ([]Category, error) { return categories, nil }
+
+	visible, err := p.filterStreams(context.Background(), streams, &RequestOptions{}, list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(visible) != 1 || visible[0].ID != 1 {
+		t.Errorf("filterStreams() = %+v", visible)
+	}
+
+	visible, err = p.filterStreams(context.Background(), streams, &RequestOptions{PIN: "1234"}, list)
+	if err != nil || len(visible) != 3 {
+		t.Errorf("filterStreams() with PIN = %d streams, %v", len(visible), err)
+	}
+
+	if _, err := p.filterStreams(context.Background(), streams, &RequestOptions{PIN: "0000"}, list); !errors.Is(err, ErrInvalidPIN) {
+		t.Errorf("filterStreams() with wrong PIN error = %v, want ErrInvalidPIN", err)
+	}
+}
@@ -0,0 +1,194 @@
+package iptv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AccountPool hands out stream URLs from several accounts of the same panel
+// without exceeding their max_connections. Every URL is tied to a Lease
+// that must be released when playback ends.
+type AccountPool struct {
+	mu        sync.Mutex
+	accounts  []*poolAccount
+	refreshed bool
+}
+
+type poolAccount struct {
+	client *Client
+
+	// From the last user_info refresh
+	max     int
+	usable  bool
+	foreign int   // connections not held by our leases
+	err     error // why the last refresh failed
+
+	leases int
+}
+
+// free returns the number of connections the account can still open
+func (a *poolAccount) free() int {
+	if !a.usable {
+		return 0
+	}
+	return a.max - a.foreign - a.leases
+}
+
+// Lease is a connection slot of an account
+type Lease struct {
+	// URL is the stream URL built with the leased account
+	URL string
+	// Username is the account holding the connection
+	Username string
+
+	pool    *AccountPool
+	account *poolAccount
+	once    sync.Once
+}
+
+// Release gives the connection back to the pool. It is safe to call more
+// than once.
+func (l *Lease) Release() {
+	l.once.Do(func() {
+		l.pool.mu.Lock()
+		defer l.pool.mu.Unlock()
+
+		l.account.leases--
+	})
+}
+
+// NewAccountPool creates a pool over clients with different credentials for
+// the same panel. Accounts count as one connection until the first refresh.
+func NewAccountPool(clients ...*Client) *AccountPool {
+	p := &AccountPool{}
+	for _, client := range clients {
+		p.accounts = append(p.accounts, &poolAccount{client: client, max: 1, usable: true})
+	}
+	return p
+}
+
+// Refresh updates the connection counts of every account from user_info.
+// Accounts that are not active, have expired or cannot be refreshed stop
+// receiving leases until a later refresh succeeds. The returned error joins
+// the failures of every account.
+func (p *AccountPool) Refresh(ctx context.Context) error {
+	infos := make([]*AccountInfo, len(p.accounts))
+	errs := make([]error, len(p.accounts))
+
+	// Accounts are independent, one failing must not cancel the others
+	var wg sync.WaitGroup
+	for i, account := range p.accounts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			info, err := account.client.AccountInfo(ctx)
+			if err != nil {
+				err = fmt.Errorf("account %s: %w", account.client.Username(), err)
+			}
+			infos[i], errs[i] = info, err
+		}()
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for i, account := range p.accounts {
+		account.err = errs[i]
+		info := infos[i]
+		if info == nil {
+			account.usable = false
+			continue
+		}
+		user := info.UserInfo
+
+		account.max = int(user.MaxConnections)
+		account.usable = (user.Status == "" || strings.EqualFold(user.Status, "Active")) &&
+			(user.ExpDate == 0 || time.Unix(int64(user.ExpDate), 0).After(now))
+
+		// The panel counts our own connections as active too
+		account.foreign = max(int(user.ActiveConnections)-account.leases, 0)
+	}
+	// Keep refreshing on first use until an account answered
+	p.refreshed = p.refreshed || slices.Contains(errs, nil)
+
+	return errors.Join(errs...)
+}
+
+// Run refreshes the pool every interval until the context is cancelled
+func (p *AccountPool) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			// Failed accounts are skipped until they refresh again
+			p.Refresh(ctx)
+		}
+	}
+}
+
+// Acquire leases a connection on the account with the most free capacity
+// and returns the stream URL for it. The pool is refreshed on first use.
+// ErrNoCapacity is returned when no account can take a lease, together with
+// the refresh errors of the accounts that failed.
+func (p *AccountPool) Acquire(ctx context.Context, stream Stream, format string) (*Lease, error) {
+	p.mu.Lock()
+	refreshed := p.refreshed
+	p.mu.Unlock()
+
+	if !refreshed {
+		// Accounts that failed are unusable, the others can still lease
+		p.Refresh(ctx)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var best *poolAccount
+	for _, account := range p.accounts {
+		if account.free() > 0 && (best == nil || account.free() > best.free()) {
+			best = account
+		}
+	}
+	if best == nil {
+		var errs []error
+		for _, account := range p.accounts {
+			errs = append(errs, account.err)
+		}
+		if err := errors.Join(errs...); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrNoCapacity, err)
+		}
+		return nil, ErrNoCapacity
+	}
+
+	best.leases++
+	return &Lease{
+		URL:      best.client.URLBuilder().StreamURL(stream, format),
+		Username: best.client.Username(),
+		pool:     p,
+		account:  best,
+	}, nil
+}
+
+// Available returns the number of connections that can still be leased
+func (p *AccountPool) Available() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	total := 0
+	for _, account := range p.accounts {
+		total += max(account.free(), 0)
+	}
+	return total
+}
@@ -0,0 +1,566 @@
+package iptv
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Query is a predicate over the fields of a stream or category. Queries are
+// built with Match, Equal, Prefix, Contains, And, Or and Not, or parsed from
+// text with ParseQuery.
+type Query interface {
+	String() string
+
+	compile() error
+	eval(get fieldGetter) bool
+	walk(fn func(*Predicate))
+}
+
+// fieldGetter returns the value of a field. The RawKey returns the whole record.
+type fieldGetter func(key string) string
+
+// RawKey is the field key that matches against the entire record
+const RawKey = "*"
+
+// Operator compares a field with a value
+type Operator string
+
+const (
+	// OpEqual matches fields equal to the value
+	OpEqual Operator = "="
+	// OpNotEqual matches fields not equal to the value
+	OpNotEqual Operator = "!="
+	// OpMatch matches fields against a regular expression
+	OpMatch Operator = "~"
+	// OpNotMatch matches fields that do not match a regular expression
+	OpNotMatch Operator = "!~"
+	// OpPrefix matches fields starting with the value
+	OpPrefix Operator = "^="
+	// OpSuffix matches fields ending with the value
+	OpSuffix Operator = "$="
+	// OpContains matches fields containing the value
+	OpContains Operator = "*="
+)
+
+// Predicate compares a single field with a value
+type Predicate struct {
+	Key        string
+	Op         Operator
+	Value      string
+	IgnoreCase bool
+
+	// The fields can change after construction, e.g. with Fold, so the
+	// regexp is recompiled when they differ from the compiled ones
+	mu       sync.Mutex
+	compiled string
+	re       *regexp.Regexp
+	err      error
+}
+
+// Match returns a predicate matching a field against a regular expression
+func Match(key, pattern string) *Predicate {
+	return &Predicate{Key: key, Op: OpMatch, Value: pattern}
+}
+
+// Equal returns a predicate matching fields equal to value
+func Equal(key, value string) *Predicate {
+	return &Predicate{Key: key, Op: OpEqual, Value: value}
+}
+
+// Prefix returns a predicate matching fields starting with prefix
+func Prefix(key, prefix string) *Predicate {
+	return &Predicate{Key: key, Op: OpPrefix, Value: prefix}
+}
+
+// Contains returns a predicate matching fields containing substr
+func Contains(key, substr string) *Predicate {
+	return &Predicate{Key: key, Op: OpContains, Value: substr}
+}
+
+// Fold makes the predicate case-insensitive
+func (p *Predicate) Fold() *Predicate {
+	p.IgnoreCase = true
+	return p
+}
+
+func (p *Predicate) String() string {
+	s := p.Key + string(p.Op) + quoteQueryValue(p.Value)
+	if p.IgnoreCase {
+		s += "i"
+	}
+	return s
+}
+
+// quoteQueryValue quotes a value the way ParseQuery reads it back
+func quoteQueryValue(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+func (p *Predicate) compile() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	current := p.String()
+	if p.compiled == current {
+		return p.err
+	}
+	p.compiled, p.re, p.err = current, nil, nil
+
+	switch p.Op {
+	case OpMatch, OpNotMatch:
+		pattern := p.Value
+		if p.IgnoreCase {
+			pattern = "(?i)" + pattern
+		}
+		p.re, p.err = regexp.Compile(pattern)
+		if p.err != nil {
+			p.err = fmt.Errorf("invalid filter regex: %w", p.err)
+		}
+	case OpEqual, OpNotEqual, OpPrefix, OpSuffix, OpContains:
+	default:
+		p.err = fmt.Errorf("%w: unknown operator %q", ErrInvalidQuery, p.Op)
+	}
+	return p.err
+}
+
+func (p *Predicate) walk(fn func(*Predicate)) {
+	fn(p)
+}
+
+func (p *Predicate) eval(get fieldGetter) bool {
+	value := get(p.Key)
+
+	switch p.Op {
+	case OpMatch:
+		return p.re.MatchString(value)
+	case OpNotMatch:
+		return !p.re.MatchString(value)
+	}
+
+	want := p.Value
+	if p.IgnoreCase {
+		value, want = strings.ToLower(value), strings.ToLower(want)
+	}
+
+	switch p.Op {
+	case OpEqual:
+		return value == want
+	case OpNotEqual:
+		return value != want
+	case OpPrefix:
+		return strings.HasPrefix(value, want)
+	case OpSuffix:
+		return strings.HasSuffix(value, want)
+	case OpContains:
+		return strings.Contains(value, want)
+	}
+	return false
+}
+
+// AndQuery matches when all of its queries match
+type AndQuery struct {
+	Queries []Query
+}
+
+// And combines queries so that all of them must match
+func And(queries ...Query) Query {
+	return &AndQuery{Queries: queries}
+}
+
+func (q *AndQuery) String() string {
+	return joinQueries(q.Queries, " AND ")
+}
+
+func (q *AndQuery) compile() error {
+	return compileQueries(q.Queries)
+}
+
+func (q *AndQuery) walk(fn func(*Predicate)) {
+	for _, sub := range q.Queries {
+		sub.walk(fn)
+	}
+}
+
+func (q *AndQuery) eval(get fieldGetter) bool {
+	for _, sub := range q.Queries {
+		if !sub.eval(get) {
+			return false
+		}
+	}
+	return true
+}
+
+// OrQuery matches when any of its queries match
+type OrQuery struct {
+	Queries []Query
+}
+
+// Or combines queries so that at least one of them must match
+func Or(queries ...Query) Query {
+	return &OrQuery{Queries: queries}
+}
+
+func (q *OrQuery) String() string {
+	return joinQueries(q.Queries, " OR ")
+}
+
+func (q *OrQuery) compile() error {
+	return compileQueries(q.Queries)
+}
+
+func (q *OrQuery) walk(fn func(*Predicate)) {
+	for _, sub := range q.Queries {
+		sub.walk(fn)
+	}
+}
+
+func (q *OrQuery) eval(get fieldGetter) bool {
+	for _, sub := range q.Queries {
+		if sub.eval(get) {
+			return true
+		}
+	}
+	return false
+}
+
+// NotQuery negates a query
+type NotQuery struct {
+	Query Query
+}
+
+// Not negates a query
+func Not(query Query) Query {
+	return &NotQuery{Query: query}
+}
+
+func (q *NotQuery) String() string {
+	if _, ok := q.Query.(*Predicate); ok {
+		return "NOT " + q.Query.String()
+	}
+	return "NOT (" + q.Query.String() + ")"
+}
+
+func (q *NotQuery) compile() error {
+	return q.Query.compile()
+}
+
+func (q *NotQuery) walk(fn func(*Predicate)) {
+	q.Query.walk(fn)
+}
+
+func (q *NotQuery) eval(get fieldGetter) bool {
+	return !q.Query.eval(get)
+}
+
+func joinQueries(queries []Query, sep string) string {
+	parts := make([]string, 0, len(queries))
+	for _, q := range queries {
+		switch q.(type) {
+		case *AndQuery, *OrQuery:
+			parts = append(parts, "("+q.String()+")")
+		default:
+			parts = append(parts, q.String())
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+func compileQueries(queries []Query) error {
+	for _, q := range queries {
+		if err := q.compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// andQueries combines an existing query with another one, either of which may be nil
+func andQueries(existing, next Query) Query {
+	switch {
+	case existing == nil:
+		return next
+	case next == nil:
+		return existing
+	}
+
+	if and, ok := existing.(*AndQuery); ok {
+		return &AndQuery{Queries: append(append([]Query{}, and.Queries...), next)}
+	}
+	return And(existing, next)
+}
+
+// ParseQuery parses the textual query syntax into a Query. Predicates have the
+// form key OP "value", where OP is one of =, !=, ~, !~, ^=, $= and *=. A
+// trailing i after the closing quote makes a predicate case-insensitive.
+// Inside quotes, \" and \\ stand for a quote and a backslash; any other
+// backslash is kept as is, so regexes such as "\d+" need no doubling.
+// Predicates combine with AND, OR, NOT and parentheses, for example:
+//
+//	group-title~"Sports" AND NOT name~"(?i)xxx"
+//	(tvg-id^="bbc." OR name*="bbc"i) AND container="m3u8"
+func ParseQuery(text string) (Query, error) {
+	p := &queryParser{input: text}
+	p.next()
+
+	q, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %q", p.tok.text)
+	}
+	if err := q.compile(); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokOperator
+	tokLParen
+	tokRParen
+	tokAnd
+	tokOr
+	tokNot
+)
+
+type queryToken struct {
+	kind tokenKind
+	text string
+	fold bool
+	pos  int
+}
+
+type queryParser struct {
+	input string
+	pos   int
+	tok   queryToken
+	err   error
+}
+
+func (p *queryParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w at offset %d: %s", ErrInvalidQuery, p.tok.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *queryParser) next() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+
+	start := p.pos
+	if p.pos >= len(p.input) {
+		p.tok = queryToken{kind: tokEOF, pos: start}
+		return
+	}
+
+	rest := p.input[p.pos:]
+	for _, op := range []string{"!=", "!~", "^=", "$=", "*=", "&&", "||", "=", "~", "!"} {
+		if !strings.HasPrefix(rest, op) {
+			continue
+		}
+		// A lone * before an operator is the raw key, not the start of *=
+		if op == "*=" && p.tok.kind != tokIdent {
+			break
+		}
+		p.pos += len(op)
+		switch op {
+		case "&&":
+			p.tok = queryToken{kind: tokAnd, text: op, pos: start}
+		case "||":
+			p.tok = queryToken{kind: tokOr, text: op, pos: start}
+		case "!":
+			p.tok = queryToken{kind: tokNot, text: op, pos: start}
+		default:
+			p.tok = queryToken{kind: tokOperator, text: op, pos: start}
+		}
+		return
+	}
+
+	switch c := p.input[p.pos]; {
+	case c == '(':
+		p.pos++
+		p.tok = queryToken{kind: tokLParen, text: "(", pos: start}
+	case c == ')':
+		p.pos++
+		p.tok = queryToken{kind: tokRParen, text: ")", pos: start}
+	case c == '"':
+		p.tok = p.readString(start)
+	case c == '*':
+		p.pos++
+		p.tok = queryToken{kind: tokIdent, text: RawKey, pos: start}
+	default:
+		for p.pos < len(p.input) && isQueryIdentChar(p.input[p.pos]) {
+			p.pos++
+		}
+		if p.pos == start {
+			p.pos++
+			p.tok = queryToken{kind: tokIdent, text: p.input[start:p.pos], pos: start}
+			p.err = fmt.Errorf("%w at offset %d: unexpected %q", ErrInvalidQuery, start, p.tok.text)
+			return
+		}
+
+		word := p.input[start:p.pos]
+		switch strings.ToUpper(word) {
+		case "AND":
+			p.tok = queryToken{kind: tokAnd, text: word, pos: start}
+		case "OR":
+			p.tok = queryToken{kind: tokOr, text: word, pos: start}
+		case "NOT":
+			p.tok = queryToken{kind: tokNot, text: word, pos: start}
+		default:
+			p.tok = queryToken{kind: tokIdent, text: word, pos: start}
+		}
+	}
+}
+
+func (p *queryParser) readString(start int) queryToken {
+	var b strings.Builder
+	p.pos++ // opening quote
+
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		switch {
+		case c == '\\' && p.pos+1 < len(p.input) && (p.input[p.pos+1] == '"' || p.input[p.pos+1] == '\\'):
+			b.WriteByte(p.input[p.pos+1])
+			p.pos += 2
+		case c == '"':
+			p.pos++
+			tok := queryToken{kind: tokString, text: b.String(), pos: start}
+			if p.pos < len(p.input) && p.input[p.pos] == 'i' &&
+				(p.pos+1 == len(p.input) || !isQueryIdentChar(p.input[p.pos+1])) {
+				tok.fold = true
+				p.pos++
+			}
+			return tok
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+
+	p.err = fmt.Errorf("%w at offset %d: unterminated string", ErrInvalidQuery, start)
+	return queryToken{kind: tokEOF, pos: start}
+}
+
+func isQueryIdentChar(c byte) bool {
+	return c == '-' || c == '_' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func (p *queryParser) parseOr() (Query, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	queries := []Query{left}
+	for p.tok.kind == tokOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, right)
+	}
+
+	if len(queries) == 1 {
+		return left, nil
+	}
+	return Or(queries...), nil
+}
+
+func (p *queryParser) parseAnd() (Query, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	queries := []Query{left}
+	for p.tok.kind == tokAnd {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, right)
+	}
+
+	if len(queries) == 1 {
+		return left, nil
+	}
+	return And(queries...), nil
+}
+
+func (p *queryParser) parseUnary() (Query, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	switch p.tok.kind {
+	case tokNot:
+		p.next()
+		q, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not(q), nil
+	case tokLParen:
+		p.next()
+		q, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRParen {
+			return nil, p.errorf("expected )")
+		}
+		p.next()
+		return q, nil
+	case tokIdent:
+		return p.parsePredicate()
+	case tokEOF:
+		return nil, p.errorf("unexpected end of query")
+	default:
+		return nil, p.errorf("unexpected %q", p.tok.text)
+	}
+}
+
+func (p *queryParser) parsePredicate() (Query, error) {
+	key := p.tok.text
+	p.next()
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	if p.tok.kind != tokOperator {
+		return nil, p.errorf("expected operator after %q", key)
+	}
+	op := Operator(p.tok.text)
+	p.next()
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	var pred *Predicate
+	switch p.tok.kind {
+	case tokString:
+		pred = &Predicate{Key: key, Op: op, Value: p.tok.text, IgnoreCase: p.tok.fold}
+	case tokIdent:
+		pred = &Predicate{Key: key, Op: op, Value: p.tok.text}
+	default:
+		return nil, p.errorf("expected value after %s%s", key, op)
+	}
+	p.next()
+
+	return pred, nil
+}
@@ -0,0 +1,189 @@
+package iptv
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	fields := map[string]string{
+		"name":        "BBC One HD",
+		"group-title": "UK | Entertainment",
+		"tvg-id":      "bbc.one.uk",
+		"container":   "m3u8",
+		"path":        `C:\tv\one`,
+		"quote":       `say "hi"`,
+		RawKey:        "BBC One HD|UK | Entertainment|bbc.one.uk",
+	}
+	get := func(key string) string { return fields[key] }
+
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{`name="BBC One HD"`, true},
+		{`name!="BBC One HD"`, false},
+		{`name="bbc one hd"`, false},
+		{`name="bbc one hd"i`, true},
+		{`name~"^BBC"`, true},
+		{`name~"^bbc"i`, true},
+		{`name!~"(?i)xxx"`, true},
+		{`name~"\d"`, false},
+		{`name~"\w+ \w+"`, true},
+		{`tvg-id^="bbc."`, true},
+		{`tvg-id$=".uk"`, true},
+		{`group-title*="Entertain"`, true},
+		{`*~"bbc\.one"`, true},
+		{`*="nope"`, false},
+		{`container=m3u8`, true},
+		{`path="C:\\tv\\one"`, true},
+		{`path="C:\tv\one"`, true},
+		{`quote="say \"hi\""`, true},
+		{`name~"BBC" AND container="m3u8"`, true},
+		{`name~"BBC" && container="ts"`, false},
+		{`name~"ITV" OR container="m3u8"`, true},
+		{`name~"ITV" || container="ts"`, false},
+		{`NOT name~"ITV"`, true},
+		{`!name~"BBC"`, false},
+		{`not (name~"ITV" or name~"C4")`, true},
+		{`(tvg-id^="bbc." OR name*="bbc"i) AND container="m3u8"`, true},
+		{`name~"ITV" OR name~"BBC" AND container="ts"`, false},
+		{`(name~"ITV" OR name~"BBC") AND container="m3u8"`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("ParseQuery() error = %v", err)
+			}
+			if got := q.eval(get); got != tt.want {
+				t.Errorf("eval() = %v, want %v (parsed as %s)", got, tt.want, q)
+			}
+		})
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []string{
+		``,
+		`name`,
+		`name=`,
+		`name="unterminated`,
+		`name="a" AND`,
+		`(name="a"`,
+		`name="a")`,
+		`name="a" name="b"`,
+		`name~"("`,
+		`@="a"`,
+	}
+
+	for _, text := range tests {
+		t.Run(text, func(t *testing.T) {
+			_, err := ParseQuery(text)
+			if err == nil {
+				t.Fatal("ParseQuery() succeeded, want an error")
+			}
+			if text != `name~"("` && !errors.Is(err, ErrInvalidQuery) {
+				t.Errorf("error %v is not ErrInvalidQuery", err)
+			}
+		})
+	}
+}
+
+func TestQueryStringRoundTrip(t *testing.T) {
+	tests := []Query{
+		Equal("name", "plain"),
+		Equal("name", `say "hi"`),
+		Match("name", `\d+\s*HD`),
+		Prefix("path", `C:\`),
+		Contains("name", `back\"slash`),
+		Equal("name", `trailing\\`),
+		Match("name", "bbc").Fold(),
+		Match(RawKey, "x"),
+		&Predicate{Key: "name", Op: OpSuffix, Value: "HD", IgnoreCase: true},
+		&Predicate{Key: "name", Op: OpNotEqual, Value: ""},
+		And(Match("name", "a"), Or(Equal("group-title", "b"), Not(Prefix("tvg-id", "c")))),
+		Not(And(Equal("name", "a"), Equal("name", "b"))),
+		Or(And(Equal("name", "a"), Equal("name", "b")), Equal("name", "c")),
+	}
+
+	for _, q := range tests {
+		text := q.String()
+		t.Run(text, func(t *testing.T) {
+			parsed, err := ParseQuery(text)
+			if err != nil {
+				t.Fatalf("ParseQuery(%s) error = %v", text, err)
+			}
+			if got := parsed.String(); got != text {
+				t.Errorf("round trip = %s, want %s", got, text)
+			}
+
+			var want, got []Predicate
+			q.walk(func(p *Predicate) {
+				want = append(want, Predicate{Key: p.Key, Op: p.Op, Value: p.Value, IgnoreCase: p.IgnoreCase})
+			})
+			parsed.walk(func(p *Predicate) {
+				got = append(got, Predicate{Key: p.Key, Op: p.Op, Value: p.Value, IgnoreCase: p.IgnoreCase})
+			})
+			if len(got) != len(want) {
+				t.Fatalf("got %d predicates, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if got[i].Key != want[i].Key || got[i].Op != want[i].Op || got[i].Value != want[i].Value || got[i].IgnoreCase != want[i].IgnoreCase {
+					t.Errorf("predicate %d = %s, want %s", i, got[i].String(), want[i].String())
+				}
+			}
+		})
+	}
+}
+
+func TestPredicateFoldAfterUse(t *testing.T) {
+	p := Match("name", "^bbc")
+	get := func(string) string { return "BBC One" }
+
+	if err := p.compile(); err != nil {
+		t.Fatal(err)
+	}
+	if p.eval(get) {
+		t.Fatal("case-sensitive predicate matched")
+	}
+
+	p.Fold()
+	if err := p.compile(); err != nil {
+		t.Fatal(err)
+	}
+	if !p.eval(get) {
+		t.Error("predicate still case-sensitive after Fold")
+	}
+}
+
+func TestWithQueryString(t *testing.T) {
+	streams := []Stream{
+		{ID: 1, Name: "BBC One", GroupTitle: "UK"},
+		{ID: 2, Name: "CNN", GroupTitle: "US"},
+		{ID: 3, Name: "BBC Two", GroupTitle: "UK"},
+	}
+
+	options := &RequestOptions{}
+	WithQueryString(`group-title="UK" AND NOT name$="Two"`)(options)
+	got, err := streamSchema.apply(streams, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != 1 {
+		t.Errorf("apply() = %+v", got)
+	}
+
+	options = &RequestOptions{}
+	WithQueryString(`unknown="x"`)(options)
+	if _, err := streamSchema.apply(streams, options); !errors.Is(err, ErrUnknownField) {
+		t.Errorf("apply() error = %v, want ErrUnknownField", err)
+	}
+
+	options = &RequestOptions{}
+	WithQueryString(`name=`)(options)
+	if _, err := streamSchema.apply(streams, options); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("apply() error = %v, want ErrInvalidQuery", err)
+	}
+}
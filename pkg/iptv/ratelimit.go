@@ -0,0 +1,228 @@
+package iptv
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// throttleFloor is the lowest fraction of the configured rate the
+	// limiter backs off to
+	throttleFloor = 16
+
+	// throttleRecoveryInterval is how often a throttled limiter raises its
+	// rate by a tenth of the configured rate
+	throttleRecoveryInterval = 30 * time.Second
+
+	// throttleMarkerSize is the largest body checked for throttle messages.
+	// Panels answer with short error messages, and real listings are larger.
+	throttleMarkerSize = 512
+)
+
+// throttleMessages are lowercase texts panels answer with, often with a 200
+// status, when a client sends too many requests
+var throttleMessages = []string{
+	"too many requests",
+	"rate limit exceeded",
+	"slow down",
+}
+
+// heavyActions download whole catalogs and have their own rate limit
+// budget. Listing actions only count as heavy without a category filter.
+var heavyActions = map[string]bool{
+	"get_vod_streams": true,
+	"get_series":      true,
+	"get.php":         true,
+	"xmltv.php":       true,
+}
+
+// isHeavy reports whether a request downloads a whole catalog
+func isHeavy(endpoint string, params map[string]string) bool {
+	if endpoint != "player_api.php" {
+		return heavyActions[endpoint]
+	}
+	return heavyActions[params["action"]] && params["category_id"] == ""
+}
+
+// adaptiveLimiter is a rate limiter that slows down when the provider
+// throttles and recovers to the configured rate over time
+type adaptiveLimiter struct {
+	limiter *rate.Limiter
+	base    rate.Limit
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+	adjusted    time.Time // last change of the rate
+	throttledAt time.Time
+}
+
+func newAdaptiveLimiter(limit rate.Limit, burst int) *adaptiveLimiter {
+	return &adaptiveLimiter{limiter: rate.NewLimiter(limit, burst), base: limit}
+}
+
+// Wait blocks until a request may be sent
+func (l *adaptiveLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.recover(now)
+	pause := l.pausedUntil.Sub(now)
+	l.mu.Unlock()
+
+	if pause > 0 {
+		timer := time.NewTimer(pause)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return l.limiter.Wait(ctx)
+}
+
+// recover raises a throttled rate by a tenth of the configured rate per
+// recovery interval. Must be called with l.mu held.
+func (l *adaptiveLimiter) recover(now time.Time) {
+	current := l.limiter.Limit()
+	if current >= l.base || now.Sub(l.adjusted) < throttleRecoveryInterval {
+		return
+	}
+	l.limiter.SetLimitAt(now, min(current+l.base/10, l.base))
+	l.adjusted = now
+}
+
+// throttled halves the rate and pauses requests for retryAfter. Signals
+// within a second of the last one are the same burst of rejected requests
+// and do not halve the rate again.
+func (l *adaptiveLimiter) throttled(retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if until := now.Add(retryAfter); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+	if now.Sub(l.throttledAt) < time.Second || l.base == rate.Inf {
+		return
+	}
+	l.limiter.SetLimitAt(now, max(l.limiter.Limit()/2, l.base/throttleFloor))
+	l.adjusted, l.throttledAt = now, now
+}
+
+// Limit returns the current rate
+func (l *adaptiveLimiter) Limit() rate.Limit {
+	return l.limiter.Limit()
+}
+
+// checkThrottle reports whether a response asks the client to slow down and
+// how long to wait. A 429 always does, a 503 only with Retry-After; without
+// it the host is failing rather than throttling. Short 200 bodies are checked
+// for throttle messages; resp keeps its full body either way.
+func checkThrottle(resp *http.Response) (time.Duration, bool) {
+	retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return retryAfter, true
+	case http.StatusServiceUnavailable:
+		return retryAfter, resp.Header.Get("Retry-After") != ""
+	case http.StatusOK:
+	default:
+		return 0, false
+	}
+
+	r := bufio.NewReaderSize(resp.Body, throttleMarkerSize+1)
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{r, resp.Body}
+
+	head, _ := r.Peek(throttleMarkerSize + 1)
+	if len(head) > throttleMarkerSize || !isThrottleMessage(head) {
+		return 0, false
+	}
+	return retryAfter, true
+}
+
+// isThrottleMessage reports whether a short body is a panel's throttle
+// message. JSON and XML answers are data, where a channel may well be called
+// "Slow Down", unless they are an error object holding only a throttle
+// message.
+func isThrottleMessage(body []byte) bool {
+	if json.Valid(body) {
+		var object map[string]any
+		if json.Unmarshal(body, &object) != nil {
+			return false
+		}
+		for _, key := range []string{"error", "message"} {
+			text, _ := object[key].(string)
+			if slices.Contains(throttleMessages, strings.ToLower(strings.Trim(text, " .!"))) {
+				return true
+			}
+		}
+		return false
+	}
+	if isXMLDocument(body) {
+		return false
+	}
+
+	body = bytes.ToLower(body)
+	for _, marker := range throttleMessages {
+		if bytes.Contains(body, []byte(marker)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isXMLDocument reports whether a body is well-formed XML other than an HTML
+// error page
+func isXMLDocument(body []byte) bool {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	root := ""
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return root != "" && !strings.EqualFold(root, "html")
+		}
+		if err != nil {
+			return false
+		}
+		if start, ok := token.(xml.StartElement); ok && root == "" {
+			root = start.Name.Local
+		}
+	}
+}
+
+// parseRetryAfter parses a Retry-After header in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
+
+// RateLimits returns the current rates of the light and heavy request
+// budgets, which are below the configured ones while the provider throttles
+func (c *Client) RateLimits() (light, heavy rate.Limit) {
+	return c.rateLimiter.Limit(), c.heavyLimiter.Limit()
+}
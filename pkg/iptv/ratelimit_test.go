@@ -0,0 +1,186 @@
+package iptv
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestCheckThrottle(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       int
+		retryAfter string
+		body       string
+		throttled  bool
+		wait       time.Duration
+	}{
+		{"too many requests", http.StatusTooManyRequests, "", "", true, 0},
+		{"too many requests with retry", http.StatusTooManyRequests, "7", "", true, 7 * time.Second},
+		{"unavailable with retry", http.StatusServiceUnavailable, "3", "", true, 3 * time.Second},
+		{"unavailable", http.StatusServiceUnavailable, "", "", false, 0},
+		{"server error", http.StatusBadGateway, "5", "", false, 0},
+		{"plain message", http.StatusOK, "", "Too many requests, slow down", true, 0},
+		{"html message", http.StatusOK, "", "<html><body>Rate limit exceeded</body></html>", true, 0},
+		{"json error", http.StatusOK, "2", `{"error":"Rate limit exceeded."}`, true, 2 * time.Second},
+		{"json data", http.StatusOK, "", `[{"stream_id":1,"name":"Slow Down"}]`, false, 0},
+		{"json other error", http.StatusOK, "", `{"error":"slow down, the server is busy"}`, false, 0},
+		{"xml data", http.StatusOK, "", `<tv><channel id="1"><display-name>Slow Down</display-name></channel></tv>`, false, 0},
+		{"long body", http.StatusOK, "", "slow down" + strings.Repeat(" ", throttleMarkerSize), false, 0},
+		{"empty", http.StatusOK, "", "", false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			if tt.retryAfter != "" {
+				rec.Header().Set("Retry-After", tt.retryAfter)
+			}
+			rec.WriteHeader(tt.code)
+			io.WriteString(rec, tt.body)
+			resp := rec.Result()
+
+			wait, throttled := checkThrottle(resp)
+			if throttled != tt.throttled || wait != tt.wait {
+				t.Errorf("checkThrottle() = %s, %v, want %s, %v", wait, throttled, tt.wait, tt.throttled)
+			}
+
+			// The body is kept for the caller
+			if body, _ := io.ReadAll(resp.Body); string(body) != tt.body {
+				t.Errorf("body = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"0", 0},
+		{"30", 30 * time.Second},
+		{"-5", 0},
+		{"soon", 0},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+
+	future := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(future); got <= 0 || got > time.Minute {
+		t.Errorf("parseRetryAfter(%q) = %s, want up to a minute", future, got)
+	}
+}
+
+func TestClientThrottling(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		// throttled requests slow the client down and are not host failures
+		throttled bool
+	}{
+		{"too many requests", failingHandler(http.StatusTooManyRequests), true},
+		{"unavailable with retry", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}, true},
+		{"throttle message", func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "Too many requests")
+		}, true},
+		{"unavailable", failingHandler(http.StatusServiceUnavailable), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := newTestPanel(t, nil)
+			primary.handle("get_live_categories", tt.handler)
+			mirror := newTestPanel(t, map[string]any{
+				"get_live_categories": []Category{{ID: "1", Name: "News"}},
+			})
+			client := newTestClientWithConfig(t,
+				&Config{BaseURL: primary.URL, BaseURLs: []string{mirror.URL}, RateLimit: 1000, RateBurst: 10},
+				WithCircuitBreaker(1, time.Hour),
+			)
+
+			_, err := client.CategoryService().GetLiveCategories(context.Background())
+			light, _ := client.RateLimits()
+			state := client.CircuitStates()[primary.URL]
+
+			if tt.throttled {
+				if err == nil {
+					t.Error("GetLiveCategories() succeeded on another host")
+				}
+				if light != 500 {
+					t.Errorf("rate = %v, want halved to 500", light)
+				}
+				if state != CircuitClosed || mirror.count("get_live_categories") != 0 {
+					t.Errorf("throttling counted as a host failure: state %s, %d mirror requests", state, mirror.count("get_live_categories"))
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("GetLiveCategories() = %v, want the mirror's answer", err)
+			}
+			if light != 1000 {
+				t.Errorf("rate = %v, want unchanged", light)
+			}
+			if state != CircuitOpen {
+				t.Errorf("state = %s, want open", state)
+			}
+		})
+	}
+}
+
+func TestAdaptiveLimiter(t *testing.T) {
+	l := newAdaptiveLimiter(100, 1)
+
+	l.throttled(0)
+	if limit := l.Limit(); limit != 50 {
+		t.Errorf("limit after throttling = %v, want 50", limit)
+	}
+
+	// The same burst of rejections halves the rate once
+	l.throttled(0)
+	if limit := l.Limit(); limit != 50 {
+		t.Errorf("limit after a second signal = %v, want 50", limit)
+	}
+
+	// The rate never drops below the floor
+	for i := 0; i < 10; i++ {
+		l.throttledAt = time.Time{}
+		l.throttled(0)
+	}
+	if limit := l.Limit(); limit != 100.0/throttleFloor {
+		t.Errorf("limit after many signals = %v, want %v", limit, 100.0/throttleFloor)
+	}
+
+	// It recovers by a tenth of the configured rate per interval
+	l.recover(l.adjusted.Add(throttleRecoveryInterval))
+	if limit := l.Limit(); limit != 100.0/throttleFloor+10 {
+		t.Errorf("limit after recovering = %v, want %v", limit, 100.0/throttleFloor+10)
+	}
+
+	// Retry-After pauses requests
+	l.throttled(time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); err == nil {
+		t.Error("Wait() did not pause")
+	}
+
+	unlimited := newAdaptiveLimiter(rate.Inf, 0)
+	unlimited.throttled(0)
+	if limit := unlimited.Limit(); limit != rate.Inf {
+		t.Errorf("unlimited limit after throttling = %v", limit)
+	}
+}
@@ -0,0 +1,332 @@
+package iptv
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// SearchKind identifies the catalog a search result comes from
+type SearchKind string
+
+const (
+	// SearchLive marks live stream results
+	SearchLive SearchKind = "live"
+	// SearchVOD marks VOD results
+	SearchVOD SearchKind = "vod"
+	// SearchSeries marks series results
+	SearchSeries SearchKind = "series"
+)
+
+// SearchResult is a ranked search match. Stream is set for live and VOD
+// results, Series for series results.
+type SearchResult struct {
+	Kind   SearchKind
+	Score  float64
+	Stream *Stream
+	Series *Series
+}
+
+// Name returns the display name of the matched item
+func (r SearchResult) Name() string {
+	if r.Series != nil {
+		return r.Series.Name
+	}
+	if r.Stream != nil {
+		return r.Stream.Name
+	}
+	return ""
+}
+
+type searchDoc struct {
+	kind   SearchKind
+	stream *Stream
+	series *Series
+	norm   string
+	tokens []string
+}
+
+// SearchIndex is an in-memory index for fuzzy, ranked search over live, VOD
+// and series names. Build it once and reuse it across queries. It is safe
+// for concurrent use.
+type SearchIndex struct {
+	mu       sync.RWMutex
+	docs     []searchDoc
+	postings map[string][]int
+}
+
+// NewSearchIndex creates an empty search index
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{postings: map[string][]int{}}
+}
+
+// BuildSearchIndex fetches the live, VOD and series catalogs and indexes
+// them. The series service may be nil.
+func BuildSearchIndex(ctx context.Context, streams StreamService, series SeriesService) (*SearchIndex, error) {
+	idx := NewSearchIndex()
+
+	live, err := streams.GetLive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	idx.AddStreams(SearchLive, live)
+
+	vod, err := streams.GetVOD(ctx)
+	if err != nil {
+		return nil, err
+	}
+	idx.AddStreams(SearchVOD, vod)
+
+	if series != nil {
+		list, err := series.GetSeries(ctx)
+		if err != nil {
+			return nil, err
+		}
+		idx.AddSeries(list)
+	}
+
+	return idx, nil
+}
+
+// AddStreams adds live or VOD streams to the index
+func (idx *SearchIndex) AddStreams(kind SearchKind, streams []Stream) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for i := range streams {
+		stream := streams[i]
+		idx.add(searchDoc{kind: kind, stream: &stream}, stream.Name)
+	}
+}
+
+// AddSeries adds series to the index
+func (idx *SearchIndex) AddSeries(series []Series) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for i := range series {
+		s := series[i]
+		idx.add(searchDoc{kind: SearchSeries, series: &s}, s.Name)
+	}
+}
+
+func (idx *SearchIndex) add(doc searchDoc, name string) {
+	doc.tokens = searchTokens(name)
+	doc.norm = strings.Join(doc.tokens, " ")
+
+	id := len(idx.docs)
+	idx.docs = append(idx.docs, doc)
+
+	seen := map[string]bool{}
+	for _, token := range doc.tokens {
+		if !seen[token] {
+			seen[token] = true
+			idx.postings[token] = append(idx.postings[token], id)
+		}
+	}
+}
+
+// Len returns the number of indexed items
+func (idx *SearchIndex) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return len(idx.docs)
+}
+
+// Search returns up to limit results ranked by relevance. Names are matched
+// token by token: exact tokens score highest, followed by prefixes, partial
+// matches and tokens within a small edit distance. Names starting with the
+// query and names with few unmatched tokens are ranked higher. Results can
+// be restricted to some kinds; by default all kinds are searched.
+func (idx *SearchIndex) Search(query string, limit int, kinds ...SearchKind) []SearchResult {
+	queryTokens := searchTokens(query)
+	if len(queryTokens) == 0 {
+		return nil
+	}
+
+	allowed := map[SearchKind]bool{}
+	for _, kind := range kinds {
+		allowed[kind] = true
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	// Similarity of every vocabulary token to each query token
+	similar := make([]map[string]float64, len(queryTokens))
+	candidates := map[int]bool{}
+	for i, q := range queryTokens {
+		similar[i] = map[string]float64{}
+		for token, docs := range idx.postings {
+			if sim := tokenSimilarity(q, token); sim > 0 {
+				similar[i][token] = sim
+				for _, id := range docs {
+					candidates[id] = true
+				}
+			}
+		}
+	}
+
+	normQuery := strings.Join(queryTokens, " ")
+	results := make([]SearchResult, 0)
+	for id := range candidates {
+		doc := &idx.docs[id]
+		if len(allowed) > 0 && !allowed[doc.kind] {
+			continue
+		}
+
+		score := scoreDoc(doc, queryTokens, similar, normQuery)
+		if score <= 0 {
+			continue
+		}
+
+		results = append(results, SearchResult{
+			Kind:   doc.kind,
+			Score:  score,
+			Stream: doc.stream,
+			Series: doc.series,
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return CompareNatural(results[i].Name(), results[j].Name()) < 0
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results
+}
+
+// scoreDoc scores a document against the query tokens. Documents matching
+// less than half of the query are dropped.
+func scoreDoc(doc *searchDoc, queryTokens []string, similar []map[string]float64, normQuery string) float64 {
+	var coverage float64
+	matched := map[int]bool{}
+
+	for i := range queryTokens {
+		best, bestPos := 0.0, -1
+		for pos, token := range doc.tokens {
+			if sim := similar[i][token]; sim > best {
+				best, bestPos = sim, pos
+			}
+		}
+		coverage += best
+		if bestPos >= 0 {
+			matched[bestPos] = true
+		}
+	}
+
+	coverage /= float64(len(queryTokens))
+	if coverage < 0.5 {
+		return 0
+	}
+
+	// Prefer names without many extra tokens
+	precision := float64(len(matched)) / float64(len(doc.tokens))
+	score := coverage + 0.2*precision
+
+	// Prefix boost
+	switch {
+	case strings.HasPrefix(doc.norm, normQuery):
+		score += 0.15
+	case strings.Contains(doc.norm, normQuery):
+		score += 0.1
+	}
+
+	return score
+}
+
+// tokenSimilarity scores how well a name token matches a query token
+func tokenSimilarity(q, token string) float64 {
+	switch {
+	case q == token:
+		return 1
+	case strings.HasPrefix(token, q):
+		return 0.85
+	case len(q) >= 2 && strings.Contains(token, q):
+		return 0.7
+	}
+
+	maxEdits := 0
+	switch n := len([]rune(q)); {
+	case n >= 8:
+		maxEdits = 2
+	case n >= 4:
+		maxEdits = 1
+	}
+	if maxEdits == 0 {
+		return 0
+	}
+
+	if d := editDistance(q, token, maxEdits); d <= maxEdits {
+		return 0.8 - 0.2*float64(d)
+	}
+	return 0
+}
+
+// editDistance returns the Levenshtein distance between a and b, or max+1
+// when it exceeds max
+func editDistance(a, b string, max int) int {
+	ra, rb := []rune(a), []rune(b)
+	if diff := len(ra) - len(rb); diff > max || -diff > max {
+		return max + 1
+	}
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, curr[j])
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// searchTokens normalises a name into lowercase, accent-free tokens split on
+// anything that is not a letter or digit
+func searchTokens(s string) []string {
+	var tokens []string
+	var b strings.Builder
+
+	flush := func() {
+		if b.Len() > 0 {
+			tokens = append(tokens, b.String())
+			b.Reset()
+		}
+	}
+
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(foldRune(r))
+		} else {
+			flush()
+		}
+	}
+	flush()
+
+	return tokens
+}
@@ -2,12 +2,11 @@ package iptv
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
-	"net/http"
-	"regexp"
 	"sort"
-	"strings"
+	"strconv"
 )
 
 type streamService struct {
@@ -19,161 +18,58 @@ func newStreamService(c *Client) StreamService {
 }
 
 func (s *streamService) GetLive(ctx context.Context, opts ...RequestOption) ([]Stream, error) {
-	options := &RequestOptions{}
-	for _, opt := range opts {
-		opt(options)
-	}
-
-	params := map[string]string{
-		"action": "get_live_streams",
-	}
-	if options.CategoryID != "" {
-		params["category_id"] = options.CategoryID
-	}
-
-	var streams []Stream
-	err := s.client.Get(ctx, params, &streams)
-	if err != nil {
-		return nil, err
-	}
-
-	return s.filterAndSort(streams, options)
+	return s.list(ctx, "get_live_streams", s.client.categories.GetLiveCategories, opts)
 }
 
 func (s *streamService) GetVOD(ctx context.Context, opts ...RequestOption) ([]Stream, error) {
+	return s.list(ctx, "get_vod_streams", s.client.categories.GetVODCategories, opts)
+}
+
+func (s *streamService) list(ctx context.Context, action string, categories func(context.Context, ...RequestOption) ([]Category, error), opts []RequestOption) ([]Stream, error) {
 	options := &RequestOptions{}
 	for _, opt := range opts {
 		opt(options)
 	}
 
-	params := map[string]string{
-		"action": "get_vod_streams",
-	}
-	if options.CategoryID != "" {
-		params["category_id"] = options.CategoryID
+	ctx, done := options.context(ctx)
+	defer done()
+
+	// Fail on a wrong PIN before anything is fetched
+	if _, err := s.client.parental.locked(options); err != nil {
+		return nil, err
 	}
 
-	var streams []Stream
-	err := s.client.Get(ctx, params, &streams)
+	categoryIDs, err := resolveCategoryIDs(ctx, options, categories)
 	if err != nil {
 		return nil, err
 	}
 
-	return s.filterAndSort(streams, options)
-}
-
-func (s *streamService) filterAndSort(streams []Stream, options *RequestOptions) ([]Stream, error) {
-	result := streams
-
-	// Apply filtering if specified
-	if options.Filter != "" {
-		filterRegex, err := regexp.Compile(options.Filter)
-		if err != nil {
-			return nil, fmt.Errorf("invalid filter regex: %w", err)
+	streams, err := fetchByCategory(ctx, categoryIDs, streamKey, func(ctx context.Context, categoryID string) ([]Stream, error) {
+		params := map[string]string{
+			"action": action,
 		}
-
-		filtered := make([]Stream, 0)
-		for _, stream := range result {
-			if options.FilterRaw {
-				// Filter against the entire stream data
-				streamStr := fmt.Sprintf("%d|%s|%s|%s|%s|%s|%s|%s|%s",
-					stream.ID, stream.Name, stream.Type, stream.StreamType,
-					stream.CategoryID, stream.AVCLevel, stream.Container,
-					stream.CustomSID, stream.DirectSource)
-				if filterRegex.MatchString(streamStr) {
-					filtered = append(filtered, stream)
-				}
-			} else {
-				// Filter against a specific key
-				var valueToMatch string
-
-				switch strings.ToLower(options.FilterKey) {
-				case "stream_id", "id":
-					valueToMatch = fmt.Sprintf("%d", stream.ID)
-				case "name":
-					valueToMatch = stream.Name
-				case "stream_type", "type":
-					valueToMatch = stream.Type
-				case "category_id":
-					valueToMatch = stream.CategoryID
-				case "container":
-					valueToMatch = stream.Container
-				// M3U specific attributes
-				case "group-title":
-					// Maps to M3U group-title attribute
-					valueToMatch = stream.GroupTitle
-					if valueToMatch == "" {
-						valueToMatch = stream.Name // Fallback if not available
-					}
-				case "tvg-id":
-					valueToMatch = stream.TVGID
-				case "tvg-name":
-					valueToMatch = stream.TVGName
-					if valueToMatch == "" {
-						valueToMatch = stream.Name // Fallback if not available
-					}
-				case "tvg-logo":
-					valueToMatch = stream.TVGLogo
-				default:
-					// Default to name if key not recognized
-					valueToMatch = stream.Name
-				}
-
-				if filterRegex.MatchString(valueToMatch) {
-					filtered = append(filtered, stream)
-				}
-			}
+		if categoryID != "" {
+			params["category_id"] = categoryID
 		}
-		result = filtered
-	}
-
-	// Apply sorting if specified
-	if options.Sort != "" {
-		sortFunc := func(i, j int) bool {
-			var comparison int
-
-			switch strings.ToLower(options.Sort) {
-			case "stream_id", "id":
-				if result[i].ID < result[j].ID {
-					comparison = -1
-				} else if result[i].ID > result[j].ID {
-					comparison = 1
-				} else {
-					comparison = 0
-				}
-			case "name":
-				comparison = strings.Compare(result[i].Name, result[j].Name)
-			case "stream_type", "type":
-				comparison = strings.Compare(result[i].Type, result[j].Type)
-			case "category_id":
-				comparison = strings.Compare(result[i].CategoryID, result[j].CategoryID)
-			case "container":
-				comparison = strings.Compare(result[i].Container, result[j].Container)
-			// M3U specific sorting
-			case "group-title":
-				comparison = strings.Compare(result[i].GroupTitle, result[j].GroupTitle)
-			case "tvg-id":
-				comparison = strings.Compare(result[i].TVGID, result[j].TVGID)
-			case "tvg-name":
-				comparison = strings.Compare(result[i].TVGName, result[j].TVGName)
-			case "tvg-logo":
-				comparison = strings.Compare(result[i].TVGLogo, result[j].TVGLogo)
-			default:
-				// Default to name if sort key not recognized
-				comparison = strings.Compare(result[i].Name, result[j].Name)
-			}
 
-			// Handle sort direction
-			if options.SortDir == SortDescending {
-				return comparison > 0
-			}
-			return comparison < 0
-		}
+		var streams []Stream
+		err := s.client.Get(ctx, params, &streams)
+		return streams, err
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		sort.SliceStable(result, sortFunc)
+	streams, err = s.client.parental.filterStreams(ctx, streams, options, categories)
+	if err != nil {
+		return nil, err
 	}
 
-	return result, nil
+	return streamSchema.apply(streams, options)
+}
+
+func streamKey(s *Stream) int {
+	return s.ID
 }
 
 func (s *streamService) GetURL(ctx context.Context, streamID int, format string) (string, error) {
@@ -182,13 +78,8 @@ func (s *streamService) GetURL(ctx context.Context, streamID int, format string)
 		return "", err
 	}
 
-	return fmt.Sprintf("%s/%s/%s/%s/%d.%s",
-		s.client.BaseURL(),
-		stream.Type,
-		s.client.Username(),
-		s.client.Password(),
-		streamID,
-		format), nil
+	stream.ID = streamID
+	return s.client.URLBuilder().StreamURL(*stream, format), nil
 }
 
 func (s *streamService) getStreamInfo(ctx context.Context, streamID int) (*Stream, error) {
@@ -216,6 +107,9 @@ func (s *categoryService) GetLiveCategories(ctx context.Context, opts ...Request
 		opt(options)
 	}
 
+	ctx, done := options.context(ctx)
+	defer done()
+
 	params := map[string]string{
 		"action": "get_live_categories",
 	}
@@ -226,7 +120,12 @@ func (s *categoryService) GetLiveCategories(ctx context.Context, opts ...Request
 		return nil, err
 	}
 
-	return s.filterAndSort(categories, options)
+	categories, err = s.client.parental.filterCategories(categories, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return categorySchema.apply(categories, options)
 }
 
 func (s *categoryService) GetVODCategories(ctx context.Context, opts ...RequestOption) ([]Category, error) {
@@ -235,6 +134,9 @@ func (s *categoryService) GetVODCategories(ctx context.Context, opts ...RequestO
 		opt(options)
 	}
 
+	ctx, done := options.context(ctx)
+	defer done()
+
 	params := map[string]string{
 		"action": "get_vod_categories",
 	}
@@ -245,7 +147,12 @@ func (s *categoryService) GetVODCategories(ctx context.Context, opts ...RequestO
 		return nil, err
 	}
 
-	return s.filterAndSort(categories, options)
+	categories, err = s.client.parental.filterCategories(categories, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return categorySchema.apply(categories, options)
 }
 
 func (s *categoryService) GetSeriesCategories(ctx context.Context, opts ...RequestOption) ([]Category, error) {
@@ -254,6 +161,9 @@ func (s *categoryService) GetSeriesCategories(ctx context.Context, opts ...Reque
 		opt(options)
 	}
 
+	ctx, done := options.context(ctx)
+	defer done()
+
 	params := map[string]string{
 		"action": "get_series_categories",
 	}
@@ -264,91 +174,138 @@ func (s *categoryService) GetSeriesCategories(ctx context.Context, opts ...Reque
 		return nil, err
 	}
 
-	return s.filterAndSort(categories, options)
+	categories, err = s.client.parental.filterCategories(categories, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return categorySchema.apply(categories, options)
 }
 
-func (s *categoryService) filterAndSort(categories []Category, options *RequestOptions) ([]Category, error) {
-	result := categories
+type seriesService struct {
+	client *Client
+}
 
-	// Apply filtering if specified
-	if options.Filter != "" {
-		filterRegex, err := regexp.Compile(options.Filter)
-		if err != nil {
-			return nil, fmt.Errorf("invalid filter regex: %w", err)
+func newSeriesService(c *Client) SeriesService {
+	return &seriesService{client: c}
+}
+
+func (s *seriesService) GetSeries(ctx context.Context, opts ...RequestOption) ([]Series, error) {
+	options := &RequestOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	ctx, done := options.context(ctx)
+	defer done()
+
+	// Fail on a wrong PIN before anything is fetched
+	if _, err := s.client.parental.locked(options); err != nil {
+		return nil, err
+	}
+
+	categoryIDs, err := resolveCategoryIDs(ctx, options, s.client.categories.GetSeriesCategories)
+	if err != nil {
+		return nil, err
+	}
+
+	series, err := fetchByCategory(ctx, categoryIDs, seriesKey, func(ctx context.Context, categoryID string) ([]Series, error) {
+		params := map[string]string{
+			"action": "get_series",
+		}
+		if categoryID != "" {
+			params["category_id"] = categoryID
 		}
 
-		filtered := make([]Category, 0)
-		for _, cat := range result {
-			if options.FilterRaw {
-				// Filter against the entire category data
-				categoryStr := fmt.Sprintf("%s|%s|%s|%d",
-					cat.ID, cat.Name, cat.Type, cat.ParentID)
-				if filterRegex.MatchString(categoryStr) {
-					filtered = append(filtered, cat)
-				}
-			} else {
-				// Filter against a specific key
-				var valueToMatch string
-
-				switch strings.ToLower(options.FilterKey) {
-				case "category_id", "id":
-					valueToMatch = cat.ID
-				case "category_name", "name":
-					valueToMatch = cat.Name
-				case "type":
-					valueToMatch = cat.Type
-				default:
-					// Default to name if key not recognized
-					valueToMatch = cat.Name
-				}
-
-				if filterRegex.MatchString(valueToMatch) {
-					filtered = append(filtered, cat)
-				}
-			}
+		var series []Series
+		err := s.client.Get(ctx, params, &series)
+		return series, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	series, err = s.client.parental.filterSeries(ctx, series, options, s.client.categories.GetSeriesCategories)
+	if err != nil {
+		return nil, err
+	}
+
+	return seriesSchema.apply(series, options)
+}
+
+func (s *seriesService) GetEpisodes(ctx context.Context, seriesID int) ([]Episode, error) {
+	params := map[string]string{
+		"action":    "get_series_info",
+		"series_id": fmt.Sprintf("%d", seriesID),
+	}
+
+	// Panels send an empty array instead of an object when there are no episodes
+	var info struct {
+		Episodes json.RawMessage `json:"episodes"`
+	}
+	if err := s.client.Get(ctx, params, &info); err != nil {
+		return nil, err
+	}
+
+	seasons := map[string][]Episode{}
+	if len(info.Episodes) > 0 && info.Episodes[0] == '{' {
+		if err := json.Unmarshal(info.Episodes, &seasons); err != nil {
+			return nil, fmt.Errorf("error decoding episodes: %w", err)
 		}
-		result = filtered
-	}
-
-	// Apply sorting if specified
-	if options.Sort != "" {
-		sortFunc := func(i, j int) bool {
-			var comparison int
-
-			switch strings.ToLower(options.Sort) {
-			case "category_id", "id":
-				comparison = strings.Compare(result[i].ID, result[j].ID)
-			case "category_name", "name":
-				comparison = strings.Compare(result[i].Name, result[j].Name)
-			case "type":
-				comparison = strings.Compare(result[i].Type, result[j].Type)
-			default:
-				// Default to name if sort key not recognized
-				comparison = strings.Compare(result[i].Name, result[j].Name)
-			}
+	}
 
-			// Handle sort direction
-			if options.SortDir == SortDescending {
-				return comparison > 0
+	episodes := make([]Episode, 0)
+	for season, list := range seasons {
+		for _, episode := range list {
+			episode.SeriesID = seriesID
+			if episode.Season == 0 {
+				n, _ := strconv.Atoi(season)
+				episode.Season = FlexInt(n)
 			}
-			return comparison < 0
+			episodes = append(episodes, episode)
 		}
-
-		sort.SliceStable(result, sortFunc)
 	}
 
-	return result, nil
+	sort.Slice(episodes, func(i, j int) bool {
+		if episodes[i].Season != episodes[j].Season {
+			return episodes[i].Season < episodes[j].Season
+		}
+		return episodes[i].EpisodeNum < episodes[j].EpisodeNum
+	})
+
+	return episodes, nil
+}
+
+func seriesKey(s *Series) int {
+	return s.ID
 }
 
 type epgService struct {
 	client *Client
 }
 
+var _ EPGRequestService = (*epgService)(nil)
+
 func newEPGService(c *Client) EPGService {
 	return &epgService{client: c}
 }
 
 func (s *epgService) GetShortEPG(ctx context.Context, streamID string, limit int) ([]EPGInfo, error) {
+	return s.GetShortEPGWithOptions(ctx, streamID, limit)
+}
+
+func (s *epgService) GetFullEPG(ctx context.Context, streamID string) ([]EPGInfo, error) {
+	return s.GetFullEPGWithOptions(ctx, streamID)
+}
+
+func (s *epgService) GetXMLTV(ctx context.Context) ([]byte, error) {
+	return s.GetXMLTVWithOptions(ctx)
+}
+
+func (s *epgService) GetShortEPGWithOptions(ctx context.Context, streamID string, limit int, opts ...RequestOption) ([]EPGInfo, error) {
+	ctx, done := requestContext(ctx, opts)
+	defer done()
+
 	params := map[string]string{
 		"action":    "get_short_epg",
 		"stream_id": streamID,
@@ -363,7 +320,10 @@ func (s *epgService) GetShortEPG(ctx context.Context, streamID string, limit int
 	return container.EPGListings, err
 }
 
-func (s *epgService) GetFullEPG(ctx context.Context, streamID string) ([]EPGInfo, error) {
+func (s *epgService) GetFullEPGWithOptions(ctx context.Context, streamID string, opts ...RequestOption) ([]EPGInfo, error) {
+	ctx, done := requestContext(ctx, opts)
+	defer done()
+
 	params := map[string]string{
 		"action":    "get_simple_data_table",
 		"stream_id": streamID,
@@ -374,26 +334,53 @@ func (s *epgService) GetFullEPG(ctx context.Context, streamID string) ([]EPGInfo
 	return container.EPGListings, err
 }
 
-func (s *epgService) GetXMLTV(ctx context.Context) ([]byte, error) {
-	baseURL := fmt.Sprintf("%s/xmltv.php", s.client.BaseURL())
-	req, err := http.NewRequestWithContext(ctx, "GET", baseURL, nil)
+func (s *epgService) GetXMLTVWithOptions(ctx context.Context, opts ...RequestOption) ([]byte, error) {
+	ctx, done := requestContext(ctx, opts)
+	defer done()
+
+	data, err := s.fetchXMLTV(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
+		if catalog := s.client.fallbackCatalog(ctx, err); catalog != nil && catalog.Guide != nil {
+			markStale(ctx)
+			return NewSnapshotService(catalog, nil).GetXMLTV(ctx)
+		}
 	}
+	return data, err
+}
 
-	req.Header.Set("User-Agent", "iptv-client")
-
-	resp, err := http.DefaultClient.Do(req)
+func (s *epgService) fetchXMLTV(ctx context.Context) ([]byte, error) {
+	resp, err := s.client.do(ctx, "xmltv.php", nil)
 	if err != nil {
-		return nil, fmt.Errorf("error performing request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	return io.ReadAll(resp.Body)
+}
+
+type playlistService struct {
+	client *Client
+}
+
+func newPlaylistService(c *Client) PlaylistService {
+	return &playlistService{client: c}
+}
+
+func (s *playlistService) GetPlaylist(ctx context.Context, output string) (*Playlist, error) {
+	params := map[string]string{
+		"type": "m3u_plus",
+	}
+	if output != "" {
+		params["output"] = output
 	}
 
-	return io.ReadAll(resp.Body)
+	resp, err := s.client.do(ctx, "get.php", params)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return ParseM3U(resp.Body)
 }
 
 // WithCategoryID sets the category ID for the request
@@ -403,29 +390,112 @@ func WithCategoryID(categoryID string) RequestOption {
 	}
 }
 
-// WithLimit sets the limit for the request
+// WithLimit limits the number of results returned after filtering and sorting
 func WithLimit(limit int) RequestOption {
 	return func(opts *RequestOptions) {
 		opts.Limit = limit
 	}
 }
 
-// WithFilter sets a filter for the request with the key to filter on and the pattern
+// WithOffset skips the first offset results after filtering and sorting
+func WithOffset(offset int) RequestOption {
+	return func(opts *RequestOptions) {
+		opts.Offset = offset
+	}
+}
+
+// WithTotal stores the number of results before offset and limit are applied
+func WithTotal(total *int) RequestOption {
+	return func(opts *RequestOptions) {
+		opts.total = total
+	}
+}
+
+// WithFilter adds a regex filter on a key to the request. An empty key
+// filters on the name. Multiple filters are combined with AND.
 func WithFilter(key, pattern string) RequestOption {
+	if key == "" {
+		key = "name"
+	}
 	return func(opts *RequestOptions) {
-		opts.Filter = pattern
-		opts.FilterKey = key
+		opts.Query = andQueries(opts.Query, Match(key, pattern))
 	}
 }
 
-// WithFilterRaw sets a raw filter (applied to the entire data) for the request
+// WithFilterRaw adds a raw filter (applied to the entire data) to the request
 func WithFilterRaw(pattern string) RequestOption {
 	return func(opts *RequestOptions) {
-		opts.Filter = pattern
-		opts.FilterRaw = true
+		opts.Query = andQueries(opts.Query, Match(RawKey, pattern))
+	}
+}
+
+// WithQuery adds a query to the request. Multiple queries are combined with AND.
+func WithQuery(query Query) RequestOption {
+	return func(opts *RequestOptions) {
+		opts.Query = andQueries(opts.Query, query)
+	}
+}
+
+// WithQueryString parses a textual query (see ParseQuery) and adds it to the
+// request. Parse errors are returned by the service call.
+func WithQueryString(text string) RequestOption {
+	return func(opts *RequestOptions) {
+		query, err := ParseQuery(text)
+		if err != nil {
+			opts.err = err
+			return
+		}
+		opts.Query = andQueries(opts.Query, query)
 	}
 }
 
+// context carries the per-request settings that Client.Get needs, such as
+// cache bypass and stale tracking. The returned function must be called once
+// the request has completed.
+func (o *RequestOptions) context(ctx context.Context) (context.Context, func()) {
+	if o.bypassCache {
+		ctx = context.WithValue(ctx, bypassCacheKey{}, true)
+	}
+	return trackStale(ctx, o.stale)
+}
+
+// requestContext applies request options that only affect the context, for
+// calls that do not filter or sort their results
+func requestContext(ctx context.Context, opts []RequestOption) (context.Context, func()) {
+	options := &RequestOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options.context(ctx)
+}
+
+// query returns the combined and compiled filter query of the options
+func (o *RequestOptions) query() (Query, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+
+	query := o.Query
+	if o.Filter != "" {
+		key := o.FilterKey
+		if o.FilterRaw {
+			key = RawKey
+		} else if key == "" {
+			key = "name"
+		}
+		query = andQueries(query, Match(key, o.Filter))
+	}
+
+	if query == nil {
+		return nil, nil
+	}
+	if err := query.compile(); err != nil {
+		return nil, err
+	}
+
+	return query, nil
+}
+
 // WithSort sets the sort key and direction for the request
 func WithSort(key string, direction SortDirection) RequestOption {
 	return func(opts *RequestOptions) {
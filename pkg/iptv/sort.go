@@ -0,0 +1,184 @@
+package iptv
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SortKey is a single key of a multi-key sort
+type SortKey struct {
+	Key string
+	Dir SortDirection
+}
+
+// WithSortKeys sorts the results by several keys in order. Each key is only
+// consulted when all previous keys compare equal.
+func WithSortKeys(keys ...SortKey) RequestOption {
+	return func(opts *RequestOptions) {
+		opts.SortKeys = append(opts.SortKeys, keys...)
+	}
+}
+
+// sortKeys returns the effective sort keys. A key set with WithSort comes first.
+func (o *RequestOptions) sortKeys() []SortKey {
+	if o.Sort == "" {
+		return o.SortKeys
+	}
+	return append([]SortKey{{Key: o.Sort, Dir: o.SortDir}}, o.SortKeys...)
+}
+
+// sortByKeys returns the items ordered by the sort keys. The values of every
+// key are extracted once per item, and the input slice is left untouched.
+func sortByKeys[T any](items []T, keys []SortKey, field func(*T, string) string) []T {
+	if len(keys) == 0 {
+		return items
+	}
+
+	values := make([][]string, len(items))
+	order := make([]int, len(items))
+	for i := range items {
+		order[i] = i
+		values[i] = make([]string, len(keys))
+		for k, key := range keys {
+			values[i][k] = field(&items[i], key.Key)
+		}
+	}
+
+	sort.SliceStable(order, func(a, b int) bool {
+		va, vb := values[order[a]], values[order[b]]
+		for k, key := range keys {
+			comparison := CompareNatural(va[k], vb[k])
+			if comparison == 0 {
+				continue
+			}
+
+			// Handle sort direction
+			if key.Dir == SortDescending {
+				return comparison > 0
+			}
+			return comparison < 0
+		}
+		return false
+	})
+
+	sorted := make([]T, len(items))
+	for i, idx := range order {
+		sorted[i] = items[idx]
+	}
+	return sorted
+}
+
+// CompareNatural compares two strings the way people expect names to sort:
+// case and accents are ignored ("élan" sorts with "Elan") and runs of digits
+// compare by numeric value ("Channel 2" before "Channel 10"). Strings that
+// are equal under these rules are ordered by a plain byte comparison so the
+// result is deterministic.
+func CompareNatural(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		ra, sa := utf8.DecodeRuneInString(a[i:])
+		rb, sb := utf8.DecodeRuneInString(b[j:])
+
+		if isDigit(ra) && isDigit(rb) {
+			ea, eb := digitRunEnd(a, i), digitRunEnd(b, j)
+			if c := compareDigits(a[i:ea], b[j:eb]); c != 0 {
+				return c
+			}
+			i, j = ea, eb
+			continue
+		}
+
+		fa, fb := foldRune(ra), foldRune(rb)
+		if fa != fb {
+			if fa < fb {
+				return -1
+			}
+			return 1
+		}
+		i += sa
+		j += sb
+	}
+
+	switch {
+	case i < len(a):
+		return 1
+	case j < len(b):
+		return -1
+	}
+	return strings.Compare(a, b)
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func digitRunEnd(s string, i int) int {
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+// compareDigits compares two runs of ASCII digits by numeric value
+func compareDigits(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// foldRune lowercases a rune and strips common Latin diacritics
+func foldRune(r rune) rune {
+	if r < utf8.RuneSelf {
+		if r >= 'A' && r <= 'Z' {
+			return r + ('a' - 'A')
+		}
+		return r
+	}
+
+	r = unicode.ToLower(r)
+	if base, ok := diacritics[r]; ok {
+		return base
+	}
+	return r
+}
+
+// diacritics maps lowercase accented Latin letters to their base letter
+var diacritics = func() map[rune]rune {
+	table := map[rune]string{
+		'a': "àáâãäåāăą",
+		'c': "çćĉċč",
+		'd': "ďđ",
+		'e': "èéêëēĕėęě",
+		'g': "ĝğġģ",
+		'h': "ĥħ",
+		'i': "ìíîïĩīĭįı",
+		'j': "ĵ",
+		'k': "ķ",
+		'l': "ĺļľŀł",
+		'n': "ñńņňŉ",
+		'o': "òóôõöøōŏő",
+		'r': "ŕŗř",
+		's': "śŝşšß",
+		't': "ţťŧ",
+		'u': "ùúûüũūŭůűų",
+		'w': "ŵ",
+		'y': "ýÿŷ",
+		'z': "źżž",
+	}
+
+	m := make(map[rune]rune)
+	for base, accented := range table {
+		for _, r := range accented {
+			m[r] = base
+		}
+	}
+	return m
+}()
@@ -0,0 +1,273 @@
+package iptv
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MediaTitle is the structured form of a VOD or series name such as
+// "EN - The Matrix (1999) [4K] MULTI-SUB"
+type MediaTitle struct {
+	Raw string
+	// Title is the clean title without prefixes, year and tags
+	Title string
+	// Year is the release year, 0 when the name does not mention one
+	Year int
+	// Resolution is one of SD, 720p, 1080p, 4K or 8K
+	Resolution string
+	// Languages holds lower-case language codes from the prefix and language
+	// tags, "mul" for multi-audio releases
+	Languages []string
+	// Subtitles is set for subtitled releases (MULTI-SUB, VOSTFR, ...)
+	Subtitles bool
+	// Editions holds edition tags such as "Extended" or "Director's Cut"
+	Editions []string
+	ThreeD   bool
+	HDR      bool
+}
+
+// ResolutionRank orders resolutions: 0 for unknown, then SD < 720p < 1080p < 4K < 8K
+func ResolutionRank(resolution string) int {
+	switch strings.ToUpper(resolution) {
+	case "SD":
+		return 1
+	case "720P":
+		return 2
+	case "1080P":
+		return 3
+	case "4K":
+		return 4
+	case "8K":
+		return 5
+	default:
+		return 0
+	}
+}
+
+// titleEditions maps edition patterns to their canonical tag
+var titleEditions = []struct {
+	re  *regexp.Regexp
+	tag string
+}{
+	{regexp.MustCompile(`(?i)\bdirector'?s\.?\s*cut\b`), "Director's Cut"},
+	{regexp.MustCompile(`(?i)\bextended(\s+(cut|edition|version))?\b`), "Extended"},
+	{regexp.MustCompile(`(?i)\btheatrical(\s+(cut|edition|version))?\b`), "Theatrical"},
+	{regexp.MustCompile(`(?i)\bfinal\s+cut\b`), "Final Cut"},
+	{regexp.MustCompile(`(?i)\bunrated\b`), "Unrated"},
+	{regexp.MustCompile(`(?i)\buncut\b`), "Uncut"},
+	{regexp.MustCompile(`(?i)\bremastered\b`), "Remastered"},
+	{regexp.MustCompile(`(?i)\bimax\b`), "IMAX"},
+	{regexp.MustCompile(`(?i)\bcriterion(\s+collection)?\b`), "Criterion"},
+	{regexp.MustCompile(`(?i)\b(special|collector'?s|anniversary|ultimate)\s+edition\b`), "Special Edition"},
+}
+
+var (
+	yearPattern = regexp.MustCompile(`^(19|20)\d{2}$`)
+	dolbyVision = regexp.MustCompile(`(?i)\bdolby\s*vision\b`)
+)
+
+// ambiguousTitleTags are tags that are also common words
+var ambiguousTitleTags = map[string]bool{"OU": true, "SUB": true, "DV": true}
+
+// titleTag classifies an upper-case title token as resolution, codec, 3d,
+// hdr, multi or sub, or returns "" for words of the title
+func titleTag(token string) string {
+	switch {
+	case titleResolution(token) != "":
+		return "resolution"
+	case isCodecTag(token) != "":
+		return "codec"
+	case token == "3D" || token == "SBS" || token == "HSBS" || token == "H-SBS" || token == "OU" || token == "HOU":
+		return "3d"
+	case token == "HDR" || token == "HDR10" || token == "HDR10+" || token == "DV":
+		return "hdr"
+	case token == "MULTI" || token == "MULTI-AUDIO" || token == "DUAL" || token == "DUAL-AUDIO":
+		return "multi"
+	case token == "MULTI-SUB" || token == "MULTISUB" || token == "SUB" || token == "SUBS" || token == "SUBBED" || strings.HasPrefix(token, "VOST"):
+		return "sub"
+	}
+	return ""
+}
+
+// ParseTitle splits a VOD or series name into its clean title, year,
+// resolution, languages, edition tags and 3D/HDR flags
+func ParseTitle(name string) MediaTitle {
+	result := MediaTitle{Raw: name}
+
+	rest := superscripts.Replace(separateSuperscripts(name))
+
+	// Scene style names use dots instead of spaces
+	if !strings.Contains(strings.TrimSpace(rest), " ") && strings.Count(rest, ".") > 1 {
+		rest = strings.ReplaceAll(rest, ".", " ")
+	}
+
+	if m := channelPrefix.FindStringSubmatch(rest); m != nil {
+		if info, ok := prefixLanguages[strings.ToUpper(m[1])]; ok {
+			result.addLanguage(info[1])
+			rest = rest[len(m[0]):]
+		}
+	}
+
+	// Editions are blanked out in place so that offsets stay valid. Passes
+	// repeat until one takes nothing, as each taken edition can leave the
+	// next one trailing a tag.
+	editions := map[string]bool{}
+	for found := true; found; {
+		found = false
+		for _, edition := range titleEditions {
+			for _, loc := range edition.re.FindAllStringIndex(rest, -1) {
+				if !isEditionPosition(rest, loc[0]) {
+					continue
+				}
+				editions[edition.tag] = true
+				rest = rest[:loc[0]] + strings.Repeat(" ", loc[1]-loc[0]) + rest[loc[1]:]
+				found = true
+			}
+		}
+	}
+	for _, edition := range titleEditions {
+		if editions[edition.tag] {
+			result.Editions = append(result.Editions, edition.tag)
+		}
+	}
+	if dolbyVision.MatchString(rest) {
+		result.HDR = true
+		rest = dolbyVision.ReplaceAllString(rest, " ")
+	}
+
+	var base []string
+
+	// A bare year is the release year when it ends the title, i.e. it is
+	// followed by tags or nothing at all, and is not the whole title
+	bareYear := -1
+	takeBareYear := func() {
+		if bareYear > 0 && bareYear == len(base)-1 && result.Year == 0 {
+			result.Year, _ = strconv.Atoi(base[bareYear])
+			base = base[:bareYear]
+		}
+		bareYear = -1
+	}
+
+	words := strings.Fields(rest)
+	afterTag := false
+	for i, word := range words {
+		bracketed := strings.ContainsAny(word, "[(") || strings.ContainsAny(word, "])")
+		trimmed := strings.Trim(word, "[]()|-•*,")
+		token := strings.ToUpper(trimmed)
+
+		// Words like "ou" or "sub" are only tags when bracketed or next to
+		// other tags, "Marche ou crève" keeps its "ou"
+		tag := titleTag(token)
+		if ambiguousTitleTags[token] && !bracketed && !afterTag &&
+			(i+1 == len(words) || titleTag(strings.ToUpper(strings.Trim(words[i+1], "[]()|-•*,"))) == "") {
+			tag = ""
+		}
+
+		switch {
+		case token == "":
+			continue
+		case yearPattern.MatchString(token) && bracketed:
+			result.Year, _ = strconv.Atoi(token)
+			afterTag = true
+			continue
+		case yearPattern.MatchString(token):
+			bareYear = len(base)
+			base = append(base, trimmed)
+			afterTag = false
+			continue
+		case tag == "resolution":
+			result.Resolution = titleResolution(token)
+		case tag == "codec":
+			// Codecs are dropped from the title
+		case tag == "3d":
+			result.ThreeD = true
+		case tag == "hdr":
+			result.HDR = true
+		case tag == "multi":
+			result.addLanguage("mul")
+		case tag == "sub":
+			result.Subtitles = true
+		case bracketed && prefixLanguages[token][1] != "":
+			result.addLanguage(prefixLanguages[token][1])
+		default:
+			base = append(base, strings.Trim(word, "|•"))
+			afterTag = false
+			continue
+		}
+
+		afterTag = true
+		takeBareYear()
+	}
+	takeBareYear()
+
+	result.Title = strings.Trim(strings.Join(base, " "), " -|:.")
+	return result
+}
+
+// isEditionPosition reports whether an edition found at offset start of s
+// is a tag: inside brackets, or after the year or another recognised tag.
+// "Uncut Gems", "The Final Cut" and "Extended Family" are titles.
+func isEditionPosition(s string, start int) bool {
+	before := s[:start]
+	if strings.Count(before, "[")+strings.Count(before, "(") > strings.Count(before, "]")+strings.Count(before, ")") {
+		return true
+	}
+
+	words := strings.Fields(before)
+	for i := len(words) - 1; i >= 0; i-- {
+		token := strings.ToUpper(strings.Trim(words[i], "[]()|-•*,"))
+		if token == "" {
+			// Separator such as " - "
+			continue
+		}
+		return yearPattern.MatchString(token) || strings.ContainsAny(words[i], "[]()") ||
+			titleTag(token) != "" && !ambiguousTitleTags[token]
+	}
+	return false
+}
+
+func (t *MediaTitle) addLanguage(code string) {
+	if code == "" {
+		return
+	}
+	for _, lang := range t.Languages {
+		if lang == code {
+			return
+		}
+	}
+	t.Languages = append(t.Languages, code)
+}
+
+// titleResolution normalises resolution tags to SD, 720p, 1080p, 4K or 8K
+func titleResolution(token string) string {
+	switch token {
+	case "SD", "480P", "576P", "DVDRIP":
+		return "SD"
+	case "HD", "720P":
+		return "720p"
+	case "FHD", "FULLHD", "1080P", "1080I":
+		return "1080p"
+	case "UHD", "4K", "2160P":
+		return "4K"
+	case "8K", "4320P":
+		return "8K"
+	}
+	return ""
+}
+
+// MediaTitle parses the stream name as a VOD title
+func (s Stream) MediaTitle() MediaTitle {
+	return ParseTitle(s.Name)
+}
+
+// MediaTitle parses the series name. The year falls back to the release date.
+func (s Series) MediaTitle() MediaTitle {
+	title := ParseTitle(s.Name)
+	if title.Year == 0 && len(s.ReleaseDate) >= 4 {
+		if year, err := strconv.Atoi(s.ReleaseDate[:4]); err == nil {
+			title.Year = year
+		}
+	}
+	return title
+}
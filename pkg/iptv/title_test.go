@@ -0,0 +1,124 @@
+package iptv
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseTitle(t *testing.T) {
+	tests := []struct {
+		name string
+		want MediaTitle
+	}{
+		{
+			name: "EN - The Matrix (1999) [4K] MULTI-SUB",
+			want: MediaTitle{Title: "The Matrix", Year: 1999, Resolution: "4K", Languages: []string{"en"}, Subtitles: true},
+		},
+		{
+			name: "Inception 2010 1080p",
+			want: MediaTitle{Title: "Inception", Year: 2010, Resolution: "1080p"},
+		},
+		{
+			name: "The.Dark.Knight.2008.720p.x264",
+			want: MediaTitle{Title: "The Dark Knight", Year: 2008, Resolution: "720p"},
+		},
+		{
+			name: "2012",
+			want: MediaTitle{Title: "2012"},
+		},
+		{
+			name: "Blade Runner 2049 (2017)",
+			want: MediaTitle{Title: "Blade Runner 2049", Year: 2017},
+		},
+		{
+			name: "Avatar (2009) [Extended Collector's Edition]",
+			want: MediaTitle{Title: "Avatar", Year: 2009, Editions: []string{"Extended", "Special Edition"}},
+		},
+		{
+			name: "Aliens (1986) Director's Cut",
+			want: MediaTitle{Title: "Aliens", Year: 1986, Editions: []string{"Director's Cut"}},
+		},
+		{
+			name: "Blade Runner (1982) - Final Cut",
+			want: MediaTitle{Title: "Blade Runner", Year: 1982, Editions: []string{"Final Cut"}},
+		},
+		{
+			name: "Gladiator 2000 Remastered Extended 4K",
+			want: MediaTitle{Title: "Gladiator", Year: 2000, Resolution: "4K", Editions: []string{"Extended", "Remastered"}},
+		},
+		{
+			name: "Dune [4K] IMAX",
+			want: MediaTitle{Title: "Dune", Resolution: "4K", Editions: []string{"IMAX"}},
+		},
+		{
+			name: "Uncut Gems (2019)",
+			want: MediaTitle{Title: "Uncut Gems", Year: 2019},
+		},
+		{
+			name: "The Final Cut",
+			want: MediaTitle{Title: "The Final Cut"},
+		},
+		{
+			name: "Extended Family",
+			want: MediaTitle{Title: "Extended Family"},
+		},
+		{
+			name: "Theatrical Release Of Unrated Things (2020) UNRATED",
+			want: MediaTitle{Title: "Theatrical Release Of Unrated Things", Year: 2020, Editions: []string{"Unrated"}},
+		},
+		{
+			name: "Avatar 3D HSBS HDR10",
+			want: MediaTitle{Title: "Avatar", ThreeD: true, HDR: true},
+		},
+		{
+			name: "Dune 2021 2160p Dolby Vision",
+			want: MediaTitle{Title: "Dune", Year: 2021, Resolution: "4K", HDR: true},
+		},
+		{
+			name: "Marche ou crève (2018)",
+			want: MediaTitle{Title: "Marche ou crève", Year: 2018},
+		},
+		{
+			name: "Avatar 3D OU",
+			want: MediaTitle{Title: "Avatar", ThreeD: true},
+		},
+		{
+			name: "Lost in Translation [SUB]",
+			want: MediaTitle{Title: "Lost in Translation", Subtitles: true},
+		},
+		{
+			name: "Sub Zero",
+			want: MediaTitle{Title: "Sub Zero"},
+		},
+		{
+			name: "FR - Amélie (2001) VOSTFR",
+			want: MediaTitle{Title: "Amélie", Year: 2001, Languages: []string{"fr"}, Subtitles: true},
+		},
+		{
+			name: "Parasite (2019) [ITA] MULTI",
+			want: MediaTitle{Title: "Parasite", Year: 2019, Languages: []string{"it", "mul"}},
+		},
+		{
+			name: "Léon [FR] DUAL",
+			want: MediaTitle{Title: "Léon", Languages: []string{"fr", "mul"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseTitle(tt.name)
+			if got.Title != tt.want.Title || got.Year != tt.want.Year || got.Resolution != tt.want.Resolution ||
+				got.Subtitles != tt.want.Subtitles || got.ThreeD != tt.want.ThreeD || got.HDR != tt.want.HDR ||
+				!slices.Equal(got.Languages, tt.want.Languages) || !slices.Equal(got.Editions, tt.want.Editions) {
+				t.Errorf("ParseTitle() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeriesMediaTitleYear(t *testing.T) {
+	series := Series{Name: "Dark", ReleaseDate: "2017-12-01"}
+	if got := series.MediaTitle(); got.Title != "Dark" || got.Year != 2017 {
+		t.Errorf("MediaTitle() = %+v", got)
+	}
+}
@@ -0,0 +1,72 @@
+package iptv
+
+import (
+	"fmt"
+	"strings"
+)
+
+// URLBuilder builds playback URLs for Xtream streams without contacting the provider
+type URLBuilder struct {
+	BaseURL  string
+	Username string
+	Password string
+}
+
+// URLBuilder returns a URL builder for the client's account on the host
+// requests currently go to
+func (c *Client) URLBuilder() *URLBuilder {
+	return &URLBuilder{
+		BaseURL:  c.BaseURL(),
+		Username: c.Username(),
+		Password: c.Password(),
+	}
+}
+
+// URLBuilders returns a URL builder per host of the client, in the order of
+// Hosts, so that players can move to the next host when playback fails
+func (c *Client) URLBuilders() []*URLBuilder {
+	hosts := c.Hosts()
+	builders := make([]*URLBuilder, len(hosts))
+	for i, baseURL := range hosts {
+		builders[i] = &URLBuilder{
+			BaseURL:  baseURL,
+			Username: c.Username(),
+			Password: c.Password(),
+		}
+	}
+	return builders
+}
+
+// StreamURL returns the playback URL of a stream in the given format.
+// VOD streams fall back to their container extension when format is empty.
+// Credentials are omitted from the path when the builder has none, which
+// allows pointing the builder at a proxy.
+func (b *URLBuilder) StreamURL(stream Stream, format string) string {
+	kind := streamPath(stream.Type)
+	if format == "" {
+		format = firstNonEmpty(stream.Container, stream.ContainerExt)
+	}
+	if format == "" {
+		format = "ts"
+	}
+
+	parts := []string{strings.TrimRight(b.BaseURL, "/"), kind}
+	if b.Username != "" || b.Password != "" {
+		parts = append(parts, b.Username, b.Password)
+	}
+	parts = append(parts, fmt.Sprintf("%d.%s", stream.ID, format))
+
+	return strings.Join(parts, "/")
+}
+
+// streamPath maps an Xtream stream type to its URL path segment
+func streamPath(streamType string) string {
+	switch strings.ToLower(streamType) {
+	case "movie", "vod":
+		return "movie"
+	case "series":
+		return "series"
+	default:
+		return "live"
+	}
+}
@@ -0,0 +1,321 @@
+package iptv
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WatchEventKind is the kind of a CatalogWatcher event
+type WatchEventKind string
+
+const (
+	// WatchLoaded is sent once the categories and streams were fetched for
+	// the first time
+	WatchLoaded WatchEventKind = "loaded"
+	// WatchChanged is sent when a refresh found changes
+	WatchChanged WatchEventKind = "changed"
+	// WatchError is sent when a refresh failed. The last good catalog is kept.
+	WatchError WatchEventKind = "error"
+)
+
+// WatchEvent is an event of a CatalogWatcher
+type WatchEvent struct {
+	Kind WatchEventKind
+	Time time.Time
+
+	// Changes is set for WatchChanged events
+	Changes *ChangeSet
+
+	// Err and Retry are set for WatchError events. Retry is the delay before
+	// the next attempt.
+	Err   error
+	Retry time.Duration
+}
+
+// WatcherOption configures a CatalogWatcher
+type WatcherOption func(*CatalogWatcher)
+
+// WithCategoryInterval sets how often categories are refreshed. Defaults to
+// one hour.
+func WithCategoryInterval(d time.Duration) WatcherOption {
+	return func(w *CatalogWatcher) {
+		w.categoryInterval = d
+	}
+}
+
+// WithStreamInterval sets how often live streams, VOD and series are
+// refreshed. Defaults to 15 minutes.
+func WithStreamInterval(d time.Duration) WatcherOption {
+	return func(w *CatalogWatcher) {
+		w.streamInterval = d
+	}
+}
+
+// WithWatcherBackoff sets the retry delay after a failed refresh. It doubles
+// with every consecutive failure up to max. Defaults to 30s and 30m.
+func WithWatcherBackoff(initial, max time.Duration) WatcherOption {
+	return func(w *CatalogWatcher) {
+		w.backoffMin, w.backoffMax = initial, max
+	}
+}
+
+// WithWatcherSeries also watches series
+func WithWatcherSeries(series SeriesService) WatcherOption {
+	return func(w *CatalogWatcher) {
+		w.series = series
+	}
+}
+
+// WithWatcherCallback calls fn for every event. Callbacks run on the watcher
+// goroutine and delay the next refresh while they run.
+func WithWatcherCallback(fn func(WatchEvent)) WatcherOption {
+	return func(w *CatalogWatcher) {
+		w.callbacks = append(w.callbacks, fn)
+	}
+}
+
+// WithEventBuffer sets the capacity of the events channel. Defaults to 16.
+func WithEventBuffer(n int) WatcherOption {
+	return func(w *CatalogWatcher) {
+		w.buffer = n
+	}
+}
+
+// CatalogWatcher keeps an always fresh copy of the catalog. Categories and
+// streams are refreshed on separate intervals; every refresh is compared with
+// the last good copy and the changes are reported as events.
+type CatalogWatcher struct {
+	streams    StreamService
+	categories CategoryService
+	series     SeriesService
+
+	categoryInterval time.Duration
+	streamInterval   time.Duration
+	backoffMin       time.Duration
+	backoffMax       time.Duration
+	callbacks        []func(WatchEvent)
+	buffer           int
+
+	events  chan WatchEvent
+	started atomic.Bool
+
+	mu      sync.RWMutex
+	catalog *Catalog
+}
+
+// NewCatalogWatcher creates a watcher on top of the stream and category
+// services. Call Run to start it.
+func NewCatalogWatcher(streams StreamService, categories CategoryService, opts ...WatcherOption) *CatalogWatcher {
+	w := &CatalogWatcher{
+		streams:          streams,
+		categories:       categories,
+		categoryInterval: time.Hour,
+		streamInterval:   15 * time.Minute,
+		backoffMin:       30 * time.Second,
+		backoffMax:       30 * time.Minute,
+		buffer:           16,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	w.events = make(chan WatchEvent, w.buffer)
+	return w
+}
+
+// Events returns the channel of watcher events. Events are dropped when the
+// channel is full. It is closed when Run returns.
+func (w *CatalogWatcher) Events() <-chan WatchEvent {
+	return w.events
+}
+
+// Catalog returns the last good catalog, or nil before the first successful
+// load. The returned catalog must not be modified.
+func (w *CatalogWatcher) Catalog() *Catalog {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.catalog
+}
+
+// Run refreshes the catalog until the context is cancelled. A watcher runs
+// once; later calls return ErrWatcherStarted.
+func (w *CatalogWatcher) Run(ctx context.Context) error {
+	if !w.started.CompareAndSwap(false, true) {
+		return ErrWatcherStarted
+	}
+	defer close(w.events)
+
+	var (
+		nextCategories   = time.Now()
+		nextStreams      = nextCategories
+		categoryFailures int
+		streamFailures   int
+		hasCategories    bool
+		hasStreams       bool
+		loaded           bool
+	)
+
+	for {
+		next := nextCategories
+		if nextStreams.Before(next) {
+			next = nextStreams
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		if !now.Before(nextCategories) {
+			if err := w.refresh(ctx, w.fetchCategories, hasCategories); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				categoryFailures++
+				retry := w.backoff(categoryFailures)
+				nextCategories = now.Add(retry)
+				w.emit(WatchEvent{Kind: WatchError, Time: now, Err: fmt.Errorf("categories: %w", err), Retry: retry})
+			} else {
+				categoryFailures = 0
+				hasCategories = true
+				nextCategories = now.Add(w.categoryInterval)
+			}
+		}
+
+		if !now.Before(nextStreams) {
+			if err := w.refresh(ctx, w.fetchStreams, hasStreams); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				streamFailures++
+				retry := w.backoff(streamFailures)
+				nextStreams = now.Add(retry)
+				w.emit(WatchEvent{Kind: WatchError, Time: now, Err: fmt.Errorf("streams: %w", err), Retry: retry})
+			} else {
+				streamFailures = 0
+				hasStreams = true
+				nextStreams = now.Add(w.streamInterval)
+			}
+		}
+
+		if !loaded && hasCategories && hasStreams {
+			loaded = true
+			w.emit(WatchEvent{Kind: WatchLoaded, Time: now})
+		}
+	}
+}
+
+// refresh fetches part of the catalog into a copy of the last good catalog
+// and swaps it in. Changes are only reported once that part was loaded.
+func (w *CatalogWatcher) refresh(ctx context.Context, fetch func(context.Context, *Catalog) error, report bool) error {
+	current := w.Catalog()
+
+	next := &Catalog{Version: CatalogVersion}
+	if current != nil {
+		*next = *current
+	}
+	// A refresh answered from a fallback snapshot, possibly this watcher's
+	// own catalog, would hide the failure and report no changes
+	if err := fetch(withoutFallback(ctx), next); err != nil {
+		return err
+	}
+	next.CreatedAt = time.Now().UTC()
+
+	w.mu.Lock()
+	w.catalog = next
+	w.mu.Unlock()
+
+	if report && current != nil {
+		if changes := DiffCatalogs(current, next); !changes.Empty() {
+			w.emit(WatchEvent{Kind: WatchChanged, Time: next.CreatedAt, Changes: changes})
+		}
+	}
+	return nil
+}
+
+// fetchCategories refreshes the categories. It bypasses the response cache,
+// which would otherwise hand back the same data until its TTL runs out.
+func (w *CatalogWatcher) fetchCategories(ctx context.Context, catalog *Catalog) error {
+	var live, vod, series []Category
+	err := runConcurrently(ctx,
+		func(ctx context.Context) (err error) {
+			live, err = w.categories.GetLiveCategories(ctx, WithCacheBypass())
+			return err
+		},
+		func(ctx context.Context) (err error) {
+			vod, err = w.categories.GetVODCategories(ctx, WithCacheBypass())
+			return err
+		},
+		func(ctx context.Context) (err error) {
+			if w.series == nil {
+				return nil
+			}
+			series, err = w.categories.GetSeriesCategories(ctx, WithCacheBypass())
+			return err
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	catalog.LiveCategories, catalog.VODCategories, catalog.SeriesCategories = live, vod, series
+	return nil
+}
+
+// fetchStreams refreshes the streams and series, bypassing the cache too
+func (w *CatalogWatcher) fetchStreams(ctx context.Context, catalog *Catalog) error {
+	var live, vod []Stream
+	var series []Series
+	err := runConcurrently(ctx,
+		func(ctx context.Context) (err error) {
+			live, err = w.streams.GetLive(ctx, WithCacheBypass())
+			return err
+		},
+		func(ctx context.Context) (err error) {
+			vod, err = w.streams.GetVOD(ctx, WithCacheBypass())
+			return err
+		},
+		func(ctx context.Context) (err error) {
+			if w.series == nil {
+				return nil
+			}
+			series, err = w.series.GetSeries(ctx, WithCacheBypass())
+			return err
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	catalog.Live, catalog.VOD, catalog.Series = live, vod, series
+	return nil
+}
+
+// backoff returns the retry delay after a number of consecutive failures
+func (w *CatalogWatcher) backoff(failures int) time.Duration {
+	delay := w.backoffMin
+	for i := 1; i < failures && delay < w.backoffMax; i++ {
+		delay *= 2
+	}
+	return min(delay, w.backoffMax)
+}
+
+// emit calls the callbacks and sends the event without blocking
+func (w *CatalogWatcher) emit(event WatchEvent) {
+	for _, fn := range w.callbacks {
+		fn(event)
+	}
+
+	select {
+	case w.events <- event:
+	default:
+	}
+}
@@ -0,0 +1,84 @@
+package iptv
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCatalogWatcherBypassesCache(t *testing.T) {
+	panel := newTestPanel(t, map[string]any{
+		"get_live_categories": []Category{{ID: "1", Name: "News"}},
+		"get_vod_categories":  []Category{},
+		"get_live_streams":    []Stream{{ID: 1, Name: "News One", CategoryID: "1"}},
+		"get_vod_streams":     []Stream{},
+	})
+	client := newTestClient(t, panel.URL, WithCache(NewMemoryCache(100)))
+	ctx := context.Background()
+
+	// Regular calls are served from the cache
+	for i := 0; i < 2; i++ {
+		if _, err := client.StreamService().GetLive(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := panel.count("get_live_streams"); n != 1 {
+		t.Fatalf("panel got %d requests for cached calls, want 1", n)
+	}
+
+	watcher := NewCatalogWatcher(client.StreamService(), client.CategoryService())
+	for i := 0; i < 2; i++ {
+		if err := watcher.refresh(ctx, watcher.fetchCategories, true); err != nil {
+			t.Fatal(err)
+		}
+		if err := watcher.refresh(ctx, watcher.fetchStreams, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := panel.count("get_live_streams"); n != 3 {
+		t.Errorf("panel got %d stream requests, want 3", n)
+	}
+	if n := panel.count("get_live_categories"); n != 2 {
+		t.Errorf("panel got %d category requests, want 2", n)
+	}
+	if catalog := watcher.Catalog(); catalog == nil || len(catalog.Live) != 1 {
+		t.Errorf("Catalog() = %+v", catalog)
+	}
+}
+
+func TestCatalogWatcherRunsOnce(t *testing.T) {
+	panel := newTestPanel(t, map[string]any{
+		"get_live_categories": []Category{},
+		"get_vod_categories":  []Category{},
+		"get_live_streams":    []Stream{},
+		"get_vod_streams":     []Stream{},
+	})
+	client := newTestClient(t, panel.URL)
+
+	watcher := NewCatalogWatcher(client.StreamService(), client.CategoryService())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- watcher.Run(ctx) }()
+
+	select {
+	case event := <-watcher.Events():
+		if event.Kind != WatchLoaded {
+			t.Fatalf("first event = %s, want %s", event.Kind, WatchLoaded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watcher did not load")
+	}
+
+	if err := watcher.Run(ctx); !errors.Is(err, ErrWatcherStarted) {
+		t.Errorf("second Run() = %v, want ErrWatcherStarted", err)
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() = %v, want context.Canceled", err)
+	}
+}
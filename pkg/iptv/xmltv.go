@@ -0,0 +1,138 @@
+package iptv
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// xmltvTime is the timestamp format of XMLTV start and stop attributes
+const xmltvTime = "20060102150405 -0700"
+
+type xmltvDoc struct {
+	XMLName    xml.Name         `xml:"tv"`
+	Channels   []xmltvChannel   `xml:"channel"`
+	Programmes []xmltvProgramme `xml:"programme"`
+}
+
+type xmltvChannel struct {
+	ID          string      `xml:"id,attr"`
+	DisplayName []xmltvText `xml:"display-name"`
+}
+
+type xmltvProgramme struct {
+	Start   string      `xml:"start,attr"`
+	Stop    string      `xml:"stop,attr"`
+	Channel string      `xml:"channel,attr"`
+	Title   []xmltvText `xml:"title"`
+	Desc    []xmltvText `xml:"desc,omitempty"`
+}
+
+type xmltvText struct {
+	Lang  string `xml:"lang,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+// ParseXMLTV reads an XMLTV guide and returns the programmes of every
+// channel ordered by start time, keyed by XMLTV channel ID (the
+// epg_channel_id of streams)
+func ParseXMLTV(r io.Reader) (map[string][]EPGInfo, error) {
+	var doc xmltvDoc
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("error decoding XMLTV: %w", err)
+	}
+
+	guide := map[string][]EPGInfo{}
+	for _, p := range doc.Programmes {
+		start, err := parseXMLTVTime(p.Start)
+		if err != nil {
+			return nil, err
+		}
+		stop, err := parseXMLTVTime(p.Stop)
+		if err != nil {
+			return nil, err
+		}
+
+		info := EPGInfo{
+			EpgID:      p.Channel,
+			Channel:    p.Channel,
+			Start:      start,
+			End:        stop,
+			StartStamp: start.Unix(),
+			StopStamp:  stop.Unix(),
+		}
+		if len(p.Title) > 0 {
+			info.Title, info.Lang = p.Title[0].Value, p.Title[0].Lang
+		}
+		if len(p.Desc) > 0 {
+			info.Description = p.Desc[0].Value
+		}
+		guide[p.Channel] = append(guide[p.Channel], info)
+	}
+
+	for _, programmes := range guide {
+		sort.SliceStable(programmes, func(i, j int) bool {
+			return programmes[i].Start.Before(programmes[j].Start)
+		})
+	}
+
+	return guide, nil
+}
+
+// parseXMLTVTime parses XMLTV timestamps with or without a zone offset
+func parseXMLTVTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(xmltvTime, s); err == nil {
+		return t, nil
+	}
+	if len(s) >= 14 {
+		if t, err := time.Parse("20060102150405", s[:14]); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid XMLTV time %q", s)
+}
+
+// WriteXMLTV writes a guide as returned by ParseXMLTV as an XMLTV document
+func WriteXMLTV(w io.Writer, guide map[string][]EPGInfo) error {
+	ids := make([]string, 0, len(guide))
+	for id := range guide {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	doc := xmltvDoc{}
+	for _, id := range ids {
+		doc.Channels = append(doc.Channels, xmltvChannel{ID: id, DisplayName: []xmltvText{{Value: id}}})
+		for _, info := range guide[id] {
+			start, stop := programmeWindow(info)
+			p := xmltvProgramme{
+				Start:   start.Format(xmltvTime),
+				Stop:    stop.Format(xmltvTime),
+				Channel: id,
+				Title:   []xmltvText{{Lang: info.Lang, Value: info.Title}},
+			}
+			if info.Description != "" {
+				p.Desc = []xmltvText{{Lang: info.Lang, Value: info.Description}}
+			}
+			doc.Programmes = append(doc.Programmes, p)
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}